@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/ahui2016/gof/model"
+	"github.com/ahui2016/gof/util"
+)
+
+// command 是一个子命令，比如 gof schema.
+// 子命令必须是命令行的第一个参数，其后可以跟其它参数（与不使用子命令时的参数相同）。
+type command struct {
+	usage string
+	run   func() error
+	load  func() // 解析参数后、执行 run 之前调用，用于加载任务文件，nil 表示不需要加载
+}
+
+// commands 是全部子命令，usage 为空字符串的是隐藏的子命令。
+// 在 initCommand 里初始化，因为 runCompletion 也需要用到 commands (避免初始化循环)。
+var commands map[string]command
+
+func initCommands() {
+	commands = map[string]command{
+		"schema":     {"print the JSON Schema of the task file", runSchema, loadMacros},
+		"migrate":    {"update the task file given by -f to the current version", runMigrate, nil},
+		"new":        {"create a task file interactively: gof new -r RECIPE [-f gof.yaml]", runNew, nil},
+		"plan":       {"save the operations of the tasks to a plan file: gof plan -f gof.yaml -out plan.json", runPlan, initTasks},
+		"status":     {"print a summary of the pending operations of every task: gof status -f gof.yaml", runStatus, initTasks},
+		"apply":      {"execute a plan file made by gof plan: gof apply [-replan] plan.json", runApply, loadMacros},
+		"selftest":   {"run the examples of every recipe in a temporary folder: gof selftest [recipe]", runSelftest, nil},
+		"completion": {"print the shell completion script: gof completion bash|zsh|fish", runCompletion, nil},
+		"__complete": {"", runComplete, nil},
+	}
+}
+
+// subcommand 是命令行指定的子命令，nil 表示没有指定子命令。
+var subcommand *command
+
+// initCommand 如果命令行的第一个参数是子命令，则解析其后的参数并返回 true.
+func initCommand() bool {
+	initCommands()
+	if len(os.Args) < 2 {
+		return false
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		return false
+	}
+	subcommand = &cmd
+	util.Panic(flag.CommandLine.Parse(os.Args[2:]))
+	names = flag.Args()
+
+	// 比如 migrate 不需要加载，因为旧版本的文件也许无法正常加载；__complete 则会自行加载并忽略错误；
+	// new 的 -f 是要生成的文件。
+	if cmd.load != nil {
+		cmd.load()
+	}
+	return true
+}
+
+// runSchema 输出任务文件的 JSON Schema.
+// 如果用 -f 指定了任务文件，则包括该文件里定义的 recipe.
+func runSchema() error {
+	blob, err := json.MarshalIndent(model.Schema(), "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(blob))
+	return nil
+}
+
+// runMigrate 把 -f 指定的任务文件更新为当前版本，原文件会被备份为 *.bak
+func runMigrate() error {
+	if *config == "" {
+		return fmt.Errorf("usage: gof migrate -f gof.yaml")
+	}
+	data, err := os.ReadFile(*config)
+	if err != nil {
+		return err
+	}
+	format := model.FormatOf(*config)
+	if format == "" {
+		format = model.FormatYAML
+	}
+	blob, warnings, err := model.Migrate(data, format)
+	if err != nil {
+		return err
+	}
+	for _, warning := range warnings {
+		log.Print("warning: ", warning)
+	}
+	if bytes.Equal(blob, data) {
+		log.Printf("%s is up to date", *config)
+		return nil
+	}
+	if err := os.WriteFile(*config+".bak", data, 0644); err != nil {
+		return err
+	}
+	if err := os.WriteFile(*config, blob, 0644); err != nil {
+		return err
+	}
+	log.Printf("%s is updated to version %d (backup: %s.bak)", *config, model.SchemaVersion, *config)
+	return nil
+}
+
+// runPlan 检查全部任务并显示将要执行的操作，如果指定了 -out 则把执行计划保存到该文件（不会修改其它文件）。
+func runPlan() error {
+	plan, err := tasks.Plan()
+	if err != nil {
+		return err
+	}
+	printPlan(plan)
+	if *planOut == "" {
+		return nil
+	}
+	blob, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(*planOut, append(blob, '\n'), 0644); err != nil {
+		return err
+	}
+	log.Printf("the plan is saved to %s, run it with: gof apply %s", *planOut, *planOut)
+	return nil
+}
+
+// runApply 执行 gof plan 保存的执行计划。
+func runApply() error {
+	if len(names) != 1 {
+		return fmt.Errorf("usage: gof apply [-replan] plan.json")
+	}
+	data, err := os.ReadFile(names[0])
+	if err != nil {
+		return err
+	}
+	var plan model.Plan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return fmt.Errorf("%s: %w", names[0], err)
+	}
+	return plan.Apply(*replan)
+}
+
+// printPlan 显示每个任务将要执行的操作。
+func printPlan(plan model.Plan) {
+	for _, tp := range plan.Tasks {
+		if tp.DryRun {
+			fmt.Printf("\n%s (%s, dry run)\n", tp.Title(), tp.Task.Recipe)
+		} else {
+			fmt.Printf("\n%s (%s)\n", tp.Title(), tp.Task.Recipe)
+		}
+		fmt.Println("----------------------")
+		if len(tp.Ops) == 0 {
+			fmt.Println("(none)")
+		}
+		for _, op := range tp.Ops {
+			fmt.Println(op)
+		}
+	}
+	fmt.Println()
+}
+
+// runStatus 检查全部任务，以表格的形式显示每个任务将要执行的操作的数量（不会修改任何文件）。
+// 没有实现 recipes.Planner 的 recipe 无法统计，显示为 "-".
+func runStatus() error {
+	status, err := tasks.Status()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TASK\tRECIPE\tADD\tUPDATE\tDELETE\tMOVE\tSIZE")
+	var total model.TaskStatus
+	for _, s := range status {
+		switch {
+		case s.Skipped != "":
+			fmt.Fprintf(w, "%s\t%s\t(%s)\t\t\t\t\n", s.Task, s.Recipe, s.Skipped)
+		case !s.Planned:
+			fmt.Fprintf(w, "%s\t%s\t-\t-\t-\t-\t-\n", s.Task, s.Recipe)
+		default:
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\t%s\n",
+				s.Task, s.Recipe, s.Adds, s.Updates, s.Deletes, s.Moves, util.FormatSize(s.Bytes))
+			total.Adds += s.Adds
+			total.Updates += s.Updates
+			total.Deletes += s.Deletes
+			total.Moves += s.Moves
+			total.Bytes += s.Bytes
+		}
+	}
+	if len(status) > 1 {
+		fmt.Fprintf(w, "TOTAL\t\t%d\t%d\t%d\t%d\t%s\n",
+			total.Adds, total.Updates, total.Deletes, total.Moves, util.FormatSize(total.Bytes))
+	}
+	return w.Flush()
+}
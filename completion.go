@@ -0,0 +1,250 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/ahui2016/gof/model"
+	"github.com/ahui2016/gof/recipes"
+)
+
+// runCompletion 输出 shell 补全脚本，用法: gof completion bash|zsh|fish
+//
+// 脚本里的子命令与参数是生成脚本时确定的，而 recipe 名称
+// 则是在补全时调用 gof __complete 获取的，因此包括 -f 指定的任务文件里定义的 recipe (macros).
+//
+// recipe 的 options 与任务名称只能写在任务文件里，命令行没有相应的参数，因此不补全
+// (编写任务文件时可使用 gof schema 配合编辑器补全 options).
+func runCompletion() error {
+	if len(names) != 1 {
+		return fmt.Errorf("usage: gof completion bash|zsh|fish")
+	}
+	tmpl, ok := completionScripts[names[0]]
+	if !ok {
+		return fmt.Errorf("unsupported shell: %s (should be bash, zsh or fish)", names[0])
+	}
+	t := template.Must(template.New(names[0]).Parse(tmpl))
+	return t.Execute(os.Stdout, completionData())
+}
+
+// runComplete 是隐藏的子命令，供补全脚本调用，每行输出一个候选项。
+// 用法: gof __complete [-f FILE] recipes|commands|flags
+// 补全时不应显示错误信息，因此加载任务文件失败时会被忽略。
+func runComplete() error {
+	if len(names) == 0 {
+		return nil
+	}
+	if *config != "" {
+		tasks, _ = model.LoadTasks(*config, *format, *profile)
+	}
+	var candidates []string
+	switch names[0] {
+	case "recipes":
+		for _, name := range recipes.Names() {
+			aliases, _ := recipes.AliasesOf(name)
+			candidates = append(candidates, name)
+			candidates = append(candidates, aliases...)
+		}
+	case "commands":
+		candidates = commandNames()
+	case "flags":
+		flag.VisitAll(func(f *flag.Flag) {
+			candidates = append(candidates, "-"+f.Name)
+		})
+	}
+	for _, candidate := range candidates {
+		fmt.Println(candidate)
+	}
+	return nil
+}
+
+// commandNames 返回全部非隐藏的子命令（已排序）。
+func commandNames() []string {
+	var cmds []string
+	for name, cmd := range commands {
+		if cmd.usage != "" {
+			cmds = append(cmds, name)
+		}
+	}
+	sort.Strings(cmds)
+	return cmds
+}
+
+// flagInfo 是生成补全脚本时用到的命令行参数信息。
+type flagInfo struct {
+	Name   string
+	Usage  string
+	IsBool bool
+}
+
+// ZshUsage 返回可以放在 zsh _arguments 的 [...] 里的说明。
+func (f flagInfo) ZshUsage() string {
+	return strings.NewReplacer("[", "(", "]", ")", "'", "", ":", " ").Replace(f.Usage)
+}
+
+// FishUsage 返回可以放在 fish 单引号字符串里的说明。
+func (f flagInfo) FishUsage() string {
+	return strings.ReplaceAll(f.Usage, "'", "")
+}
+
+func completionData() interface{} {
+	var flags []flagInfo
+	flag.VisitAll(func(f *flag.Flag) {
+		b, ok := f.Value.(interface{ IsBoolFlag() bool })
+		flags = append(flags, flagInfo{
+			Name:   f.Name,
+			Usage:  f.Usage,
+			IsBool: ok && b.IsBoolFlag(),
+		})
+	})
+	return struct {
+		Flags    []flagInfo
+		Commands []string
+		Formats  []string
+	}{
+		Flags:    flags,
+		Commands: commandNames(),
+		Formats:  []string{model.FormatYAML, model.FormatJSON, model.FormatTOML},
+	}
+}
+
+// completionScripts 是各种 shell 的补全脚本模板。
+var completionScripts = map[string]string{
+	"bash": bashCompletion,
+	"zsh":  zshCompletion,
+	"fish": fishCompletion,
+}
+
+const bashCompletion = `# bash completion for gof, generated by: gof completion bash
+# usage: source <(gof completion bash)
+
+_gof() {
+    local cur prev i
+    local -a args
+    cur="${COMP_WORDS[COMP_CWORD]}"
+    prev="${COMP_WORDS[COMP_CWORD-1]}"
+    COMPREPLY=()
+
+    # 把命令行里已有的 -f 传给 gof __complete
+    for ((i = 1; i < COMP_CWORD - 1; i++)); do
+        case "${COMP_WORDS[i]}" in
+            -f) args+=("${COMP_WORDS[i]}" "${COMP_WORDS[i+1]}") ;;
+        esac
+    done
+
+    case "$prev" in
+        -r)
+            COMPREPLY=($(compgen -W "$(gof __complete "${args[@]}" recipes 2>/dev/null)" -- "$cur"))
+            return ;;
+        -format)
+            COMPREPLY=($(compgen -W "{{range .Formats}}{{.}} {{end}}" -- "$cur"))
+            return ;;
+        -f|-names-from)
+            return ;; # 由 complete -o default 补全文件名
+{{- range .Flags}}{{if not .IsBool}}{{if not (or (eq .Name "r") (eq .Name "format") (eq .Name "f") (eq .Name "names-from"))}}
+        -{{.Name}})
+            return ;;
+{{- end}}{{end}}{{end}}
+    esac
+
+    if [[ $cur == -* ]]; then
+        COMPREPLY=($(compgen -W "{{range .Flags}}-{{.Name}} {{end}}" -- "$cur"))
+    elif [[ $COMP_CWORD -eq 1 ]]; then
+        COMPREPLY=($(compgen -W "{{range .Commands}}{{.}} {{end}}" -- "$cur"))
+    elif [[ $COMP_CWORD -eq 2 && ${COMP_WORDS[1]} == "completion" ]]; then
+        COMPREPLY=($(compgen -W "bash zsh fish" -- "$cur"))
+    fi
+}
+
+complete -o default -F _gof gof
+`
+
+const zshCompletion = `#compdef gof
+# zsh completion for gof, generated by: gof completion zsh
+# usage: gof completion zsh > "${fpath[1]}/_gof"
+
+_gof_complete() {
+    local -a args
+    [[ -n ${opt_args[-f]} ]] && args+=(-f "${(Q)opt_args[-f]}")
+    gof __complete "${args[@]}" "$@" 2>/dev/null
+}
+
+_gof_recipes() {
+    local -a candidates
+    candidates=(${(f)"$(_gof_complete recipes)"})
+    _describe -t recipes 'recipe' candidates
+}
+
+_gof_args() {
+    if (( CURRENT == 2 )); then
+        local -a cmds
+        cmds=({{range .Commands}}{{.}} {{end}})
+        _describe -t commands 'command' cmds
+    elif [[ ${words[2]} == completion ]]; then
+        _values 'shell' bash zsh fish
+        return
+    fi
+    _files
+}
+
+_gof() {
+    _arguments -s \
+{{- range .Flags}}
+{{- if .IsBool}}
+        '-{{.Name}}[{{.ZshUsage}}]' \
+{{- else if or (eq .Name "f") (eq .Name "names-from")}}
+        '-{{.Name}}[{{.ZshUsage}}]:file:_files' \
+{{- else if eq .Name "r"}}
+        '-{{.Name}}[{{.ZshUsage}}]:recipe:_gof_recipes' \
+{{- else if eq .Name "format"}}
+        '-{{.Name}}[{{.ZshUsage}}]:format:({{range $.Formats}}{{.}} {{end}})' \
+{{- else}}
+        '-{{.Name}}[{{.ZshUsage}}]:{{.Name}}:' \
+{{- end}}
+{{- end}}
+        '*:name:_gof_args'
+}
+
+compdef _gof gof
+`
+
+const fishCompletion = `# fish completion for gof, generated by: gof completion fish
+# usage: gof completion fish > ~/.config/fish/completions/gof.fish
+
+# __gof_complete 把命令行里已有的 -f 传给 gof __complete
+function __gof_complete
+    set -l tokens (commandline -opc)
+    set -l args
+    for i in (seq 2 (math (count $tokens) - 1))
+        switch $tokens[$i]
+            case -f
+                set -a args $tokens[$i] $tokens[(math $i + 1)]
+        end
+    end
+    gof __complete $args $argv 2>/dev/null
+end
+
+function __gof_no_command
+    test (count (commandline -opc)) -eq 1
+end
+
+complete -c gof -n __gof_no_command -f -a '{{range .Commands}}{{.}} {{end}}'
+complete -c gof -n '__fish_seen_subcommand_from completion' -f -a 'bash zsh fish'
+{{- range .Flags}}
+{{- if .IsBool}}
+complete -c gof -o {{.Name}} -d '{{.FishUsage}}'
+{{- else if or (eq .Name "f") (eq .Name "names-from")}}
+complete -c gof -o {{.Name}} -r -F -d '{{.FishUsage}}'
+{{- else if eq .Name "r"}}
+complete -c gof -o {{.Name}} -x -a '(__gof_complete recipes)' -d '{{.FishUsage}}'
+{{- else if eq .Name "format"}}
+complete -c gof -o {{.Name}} -x -a '{{range $.Formats}}{{.}} {{end}}' -d '{{.FishUsage}}'
+{{- else}}
+complete -c gof -o {{.Name}} -x -d '{{.FishUsage}}'
+{{- end}}
+{{- end}}
+`
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ahui2016/gof/filesys"
+	"github.com/ahui2016/gof/model"
+	"github.com/ahui2016/gof/recipes"
+)
+
+// confirmer 实现了 model.Confirmer 接口，在执行任务前显示将要执行的操作并请使用者确认。
+// 提示信息输出到 stderr, 以免与 -print-names 的输出混在一起。
+type confirmer struct {
+	interactive bool // -i: 每个任务都要确认
+	maxDeletes  int  // 删除的文件数量（包括文件夹里的文件）超过 maxDeletes 时，即使没有 -i 也要确认 (0 表示不限)
+	all         bool // 使用者选择了 all, 之后不再因 -i 而确认（但超过 maxDeletes 时仍要确认）
+	in          *bufio.Reader
+	out         io.Writer
+}
+
+// errAborted 表示使用者选择了退出。
+var errAborted = fmt.Errorf("aborted by user")
+
+// ask 显示提示并读取一行输入（转换为小写）。
+func (c *confirmer) ask(prompt string) (string, error) {
+	fmt.Fprint(c.out, prompt)
+	line, err := c.in.ReadString('\n')
+	if err == io.EOF && line == "" {
+		return "", fmt.Errorf("no answer to the prompt (use -yes to skip prompts)")
+	}
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return strings.ToLower(strings.TrimSpace(line)), nil
+}
+
+func (c *confirmer) ConfirmTask(task string) (bool, error) {
+	if !c.interactive || c.all {
+		return true, nil
+	}
+	for {
+		answer, err := c.ask(fmt.Sprintf(
+			"\n%s: its operations cannot be listed, run it? [y]es/[n]o/[a]ll/[q]uit: ", task))
+		if err != nil {
+			return false, err
+		}
+		switch answer {
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		case "a", "all":
+			c.all = true
+			return true, nil
+		case "q", "quit":
+			return false, errAborted
+		}
+	}
+}
+
+func (c *confirmer) ConfirmOps(task string, ops []recipes.Op) ([]recipes.Op, error) {
+	deletes, _, err := model.CountDeletes(filesys.OS{}, ops)
+	if err != nil {
+		return nil, err
+	}
+	tooMany := c.maxDeletes > 0 && deletes > c.maxDeletes
+	if !tooMany && (!c.interactive || c.all) {
+		return ops, nil
+	}
+
+	c.printOps(task, ops)
+	if tooMany {
+		fmt.Fprintf(c.out, "warning: %s will delete %d files and folders (more than %d, see -confirm-deletes)\n",
+			task, deletes, c.maxDeletes)
+	}
+	for {
+		answer, err := c.ask(fmt.Sprintf(
+			"%s: run these %d operations? [y]es/[n]o/[e]ach/[a]ll/[q]uit: ", task, len(ops)))
+		if err != nil {
+			return nil, err
+		}
+		switch answer {
+		case "y", "yes":
+			return ops, nil
+		case "n", "no":
+			return nil, nil
+		case "e", "each":
+			return c.confirmEach(ops)
+		case "a", "all":
+			c.all = true
+			return ops, nil
+		case "q", "quit":
+			return nil, errAborted
+		}
+	}
+}
+
+// confirmEach 逐一确认每个操作。
+func (c *confirmer) confirmEach(ops []recipes.Op) (confirmed []recipes.Op, err error) {
+	for i, op := range ops {
+		answer, err := c.ask(fmt.Sprintf(
+			"[%d/%d] %s? [y]es/[n]o/[r]est (yes to the rest)/[s]kip (no to the rest)/[q]uit: ", i+1, len(ops), op))
+		if err != nil {
+			return nil, err
+		}
+		switch answer {
+		case "y", "yes":
+			confirmed = append(confirmed, op)
+		case "r", "rest":
+			return append(confirmed, ops[i:]...), nil
+		case "s", "skip":
+			return confirmed, nil
+		case "q", "quit":
+			return nil, errAborted
+		}
+	}
+	return confirmed, nil
+}
+
+// opGroups 用于分组显示将要执行的操作。
+var opGroups = []struct {
+	title string
+	kinds []string
+}{
+	{"add", []string{recipes.OpMkdir, recipes.OpAdd}},
+	{"update", []string{recipes.OpUpdate}},
+	{"delete", []string{recipes.OpDelete}},
+	{"move", []string{recipes.OpMove, recipes.OpRename}},
+}
+
+// printOps 按 add/update/delete/move 分组显示将要执行的操作。
+func (c *confirmer) printOps(task string, ops []recipes.Op) {
+	fmt.Fprintf(c.out, "\n%s\n", task)
+	for _, group := range opGroups {
+		var lines []string
+		for _, op := range ops {
+			for _, kind := range group.kinds {
+				if op.Kind == kind {
+					lines = append(lines, op.String())
+				}
+			}
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		fmt.Fprintln(c.out)
+		fmt.Fprintf(c.out, "%s (%d)\n", group.title, len(lines))
+		fmt.Fprintln(c.out, "----------------------")
+		for _, line := range lines {
+			fmt.Fprintln(c.out, line)
+		}
+	}
+	fmt.Fprintln(c.out)
+}
@@ -0,0 +1,166 @@
+// Package filesys 定义了 recipe 访问文件系统的接口 FS, 以便由框架注入不同的实现，
+// 比如在 Prepare, Validate 以及 dry run 时注入只读的 ReadOnly, 确保 recipe 不会修改文件；
+// 又比如用保存在内存里的 Mem 测试 recipe. 平时采用的是直接调用 os 包的 OS.
+package filesys
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FS 是 recipe 访问文件系统的接口，各个方法的用法与 os 包里的同名函数相同。
+type FS interface {
+	Lstat(name string) (fs.FileInfo, error)
+	Stat(name string) (fs.FileInfo, error)
+	ReadDir(name string) ([]fs.DirEntry, error)
+	WalkDir(root string, fn fs.WalkDirFunc) error
+	Open(name string) (fs.File, error)
+
+	Create(name string) (File, error)
+	Mkdir(name string, perm fs.FileMode) error
+	Rename(oldpath, newpath string) error
+	Remove(name string) error
+	RemoveAll(name string) error
+	Chtimes(name string, atime time.Time, mtime time.Time) error
+}
+
+// File 是用 FS.Create 新建的文件。
+type File interface {
+	io.WriteCloser
+	Sync() error
+}
+
+// OS 实现了 FS 接口，直接调用 os 包里的函数。
+type OS struct{}
+
+func (OS) Lstat(name string) (fs.FileInfo, error)       { return os.Lstat(name) }
+func (OS) Stat(name string) (fs.FileInfo, error)        { return os.Stat(name) }
+func (OS) ReadDir(name string) ([]fs.DirEntry, error)   { return os.ReadDir(name) }
+func (OS) WalkDir(root string, fn fs.WalkDirFunc) error { return filepath.WalkDir(root, fn) }
+func (OS) Open(name string) (fs.File, error)            { return os.Open(name) }
+func (OS) Create(name string) (File, error)             { return os.Create(name) }
+func (OS) Mkdir(name string, perm fs.FileMode) error    { return os.Mkdir(name, perm) }
+func (OS) Rename(oldpath, newpath string) error         { return os.Rename(oldpath, newpath) }
+func (OS) Remove(name string) error                     { return os.Remove(name) }
+func (OS) RemoveAll(name string) error                  { return os.RemoveAll(name) }
+
+func (OS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return os.Chtimes(name, atime, mtime)
+}
+
+// ErrReadOnly 表示试图通过 ReadOnly 修改文件。
+var ErrReadOnly = errors.New("read-only file system (Prepare, Validate and dry run must not modify files)")
+
+// ReadOnly 实现了 FS 接口，读取文件时调用 FS, 而任何修改都会返回 ErrReadOnly.
+type ReadOnly struct {
+	FS FS
+}
+
+func (r ReadOnly) Lstat(name string) (fs.FileInfo, error)       { return r.FS.Lstat(name) }
+func (r ReadOnly) Stat(name string) (fs.FileInfo, error)        { return r.FS.Stat(name) }
+func (r ReadOnly) ReadDir(name string) ([]fs.DirEntry, error)   { return r.FS.ReadDir(name) }
+func (r ReadOnly) WalkDir(root string, fn fs.WalkDirFunc) error { return r.FS.WalkDir(root, fn) }
+func (r ReadOnly) Open(name string) (fs.File, error)            { return r.FS.Open(name) }
+
+func (ReadOnly) Create(name string) (File, error)          { return nil, readOnly("create", name) }
+func (ReadOnly) Mkdir(name string, perm fs.FileMode) error { return readOnly("mkdir", name) }
+func (ReadOnly) Rename(oldpath, newpath string) error      { return readOnly("rename", oldpath) }
+func (ReadOnly) Remove(name string) error                  { return readOnly("remove", name) }
+func (ReadOnly) RemoveAll(name string) error               { return readOnly("remove", name) }
+
+func (ReadOnly) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return readOnly("chtimes", name)
+}
+
+func readOnly(op, name string) error {
+	return &fs.PathError{Op: op, Path: name, Err: ErrReadOnly}
+}
+
+// PathIsNotExist 找不到名为 name 的文件时返回 true, 否则返回 false
+func PathIsNotExist(fsys FS, name string) (ok bool, err error) {
+	_, err = fsys.Lstat(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		ok = true
+		err = nil
+	}
+	return
+}
+
+// PathIsExist 找到名为 name 的文件时返回 true, 否则返回 false
+func PathIsExist(fsys FS, name string) (bool, error) {
+	ok, err := PathIsNotExist(fsys, name)
+	return !ok, err
+}
+
+// FindFile returns a better error massage if cannot find the file.
+func FindFile(fsys FS, name string) error {
+	_, err := fsys.Lstat(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("the system cannot find: %s", name)
+	}
+	return err
+}
+
+// MkdirAll 新建文件夹 name 及其上层文件夹，与 os.MkdirAll 一样，name 已存在时不返回错误。
+func MkdirAll(fsys FS, name string) error {
+	path, err := filepath.Abs(name)
+	if err != nil {
+		return err
+	}
+	return mkdirAll(fsys, path)
+}
+
+func mkdirAll(fsys FS, name string) error {
+	info, err := fsys.Stat(name)
+	if err == nil {
+		if info.IsDir() {
+			return nil
+		}
+		return &fs.PathError{Op: "mkdir", Path: name, Err: errNotDir}
+	}
+	if parent := filepath.Dir(name); parent != name {
+		if err := mkdirAll(fsys, parent); err != nil {
+			return err
+		}
+	}
+	err = fsys.Mkdir(name, os.ModePerm)
+	if errors.Is(err, fs.ErrExist) {
+		return nil
+	}
+	return err
+}
+
+// ReadFile 读取文件 name 的全部内容。
+func ReadFile(fsys FS, name string) ([]byte, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// CopyFile 把文件 sourcePath 复制为 destPath.
+func CopyFile(fsys FS, destPath, sourcePath string) error {
+	inputFile, err := fsys.Open(sourcePath)
+	if err != nil {
+		return err
+	}
+	defer inputFile.Close()
+
+	outputFile, err := fsys.Create(destPath)
+	if err != nil {
+		return err
+	}
+	defer outputFile.Close()
+
+	if _, err := io.Copy(outputFile, inputFile); err != nil {
+		return err
+	}
+	return outputFile.Sync()
+}
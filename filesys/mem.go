@@ -0,0 +1,386 @@
+package filesys
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mem 实现了 FS 接口，把文件保存在内存里，用于测试 recipe 等。
+// 相对路径与 os 包一样是相对于当前文件夹 (参见 filepath.Abs)，
+// 因此 recipe 可以照常使用相对路径与绝对路径。不支持 symlink 与权限。
+// 根目录总是存在，其它文件夹需要用 Mkdir 或 MkdirAll 新建。
+type Mem struct {
+	mu    sync.Mutex
+	nodes map[string]*memNode // key 是绝对路径
+}
+
+// memNode 是 Mem 里的一个文件或文件夹。
+type memNode struct {
+	isDir   bool
+	data    []byte
+	modTime time.Time
+}
+
+// NewMem 返回一个空的 Mem.
+func NewMem() *Mem {
+	return &Mem{nodes: make(map[string]*memNode)}
+}
+
+// abs 返回 name 的绝对路径。
+func (m *Mem) abs(op, name string) (string, error) {
+	path, err := filepath.Abs(name)
+	if err != nil {
+		return "", &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return path, nil
+}
+
+// isRoot 在 path 是根目录时返回 true.
+func isRoot(path string) bool {
+	return filepath.Dir(path) == path
+}
+
+// node 返回 path 对应的文件，根目录总是存在。调用者必须持有 m.mu.
+func (m *Mem) node(path string) (*memNode, bool) {
+	if isRoot(path) {
+		return &memNode{isDir: true}, true
+	}
+	node, ok := m.nodes[path]
+	return node, ok
+}
+
+// checkParent 检查 path 的上层文件夹是否存在。调用者必须持有 m.mu.
+func (m *Mem) checkParent(op, name, path string) error {
+	parent, ok := m.node(filepath.Dir(path))
+	if !ok {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	if !parent.isDir {
+		return &fs.PathError{Op: op, Path: name, Err: errNotDir}
+	}
+	return nil
+}
+
+// children 返回文件夹 path 里的第一层文件的名称（已排序）。调用者必须持有 m.mu.
+func (m *Mem) children(path string) (names []string) {
+	for key := range m.nodes {
+		if key != path && filepath.Dir(key) == path {
+			names = append(names, filepath.Base(key))
+		}
+	}
+	sort.Strings(names)
+	return
+}
+
+// isInside 在 key 位于文件夹 path 之内时返回 true.
+func isInside(key, path string) bool {
+	prefix := path
+	if !strings.HasSuffix(prefix, string(filepath.Separator)) {
+		prefix += string(filepath.Separator)
+	}
+	return strings.HasPrefix(key, prefix)
+}
+
+var (
+	errNotDir   = errors.New("not a directory")
+	errIsDir    = errors.New("is a directory")
+	errNotEmpty = errors.New("directory not empty")
+)
+
+func (m *Mem) Lstat(name string) (fs.FileInfo, error) {
+	return m.stat("lstat", name)
+}
+
+func (m *Mem) Stat(name string) (fs.FileInfo, error) {
+	return m.stat("stat", name)
+}
+
+func (m *Mem) stat(op, name string) (fs.FileInfo, error) {
+	path, err := m.abs(op, name)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	node, ok := m.node(path)
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return node.info(path), nil
+}
+
+func (m *Mem) ReadDir(name string) ([]fs.DirEntry, error) {
+	path, err := m.abs("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	node, ok := m.node(path)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	if !node.isDir {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errNotDir}
+	}
+	var entries []fs.DirEntry
+	for _, base := range m.children(path) {
+		child := filepath.Join(path, base)
+		entries = append(entries, fs.FileInfoToDirEntry(m.nodes[child].info(child)))
+	}
+	return entries, nil
+}
+
+// WalkDir 与 filepath.WalkDir 一样按文件名的顺序遍历，传给 fn 的路径以 root 开头。
+func (m *Mem) WalkDir(root string, fn fs.WalkDirFunc) error {
+	info, err := m.Lstat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = m.walkDir(root, fs.FileInfoToDirEntry(info), fn)
+	}
+	if err == fs.SkipDir {
+		return nil
+	}
+	return err
+}
+
+func (m *Mem) walkDir(name string, d fs.DirEntry, fn fs.WalkDirFunc) error {
+	if err := fn(name, d, nil); err != nil || !d.IsDir() {
+		if err == fs.SkipDir && d.IsDir() {
+			err = nil
+		}
+		return err
+	}
+	entries, err := m.ReadDir(name)
+	if err != nil {
+		if err = fn(name, d, err); err != nil {
+			if err == fs.SkipDir && d.IsDir() {
+				err = nil
+			}
+			return err
+		}
+	}
+	for _, entry := range entries {
+		if err := m.walkDir(filepath.Join(name, entry.Name()), entry, fn); err != nil {
+			if err == fs.SkipDir {
+				break
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Mem) Open(name string) (fs.File, error) {
+	path, err := m.abs("open", name)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	node, ok := m.node(path)
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	data := append([]byte(nil), node.data...)
+	return &memReader{info: node.info(path), Reader: bytes.NewReader(data)}, nil
+}
+
+func (m *Mem) Create(name string) (File, error) {
+	path, err := m.abs("open", name)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if node, ok := m.node(path); ok && node.isDir {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errIsDir}
+	}
+	if err := m.checkParent("open", name, path); err != nil {
+		return nil, err
+	}
+	node := &memNode{modTime: time.Now()}
+	m.nodes[path] = node
+	return &memWriter{mem: m, node: node}, nil
+}
+
+func (m *Mem) Mkdir(name string, perm fs.FileMode) error {
+	path, err := m.abs("mkdir", name)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.node(path); ok {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	if err := m.checkParent("mkdir", name, path); err != nil {
+		return err
+	}
+	m.nodes[path] = &memNode{isDir: true, modTime: time.Now()}
+	return nil
+}
+
+// Rename 与 os.Rename 一样，可以覆盖已有的文件或空文件夹，文件夹会连同其内容一起改名。
+func (m *Mem) Rename(oldpath, newpath string) error {
+	oldAbs, err := m.abs("rename", oldpath)
+	if err != nil {
+		return err
+	}
+	newAbs, err := m.abs("rename", newpath)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	linkErr := func(err error) error {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: err}
+	}
+	node, ok := m.node(oldAbs)
+	if !ok {
+		return linkErr(fs.ErrNotExist)
+	}
+	if oldAbs == newAbs {
+		return nil
+	}
+	if isRoot(oldAbs) || isInside(newAbs, oldAbs) {
+		return linkErr(fs.ErrInvalid)
+	}
+	if target, ok := m.node(newAbs); ok {
+		switch {
+		case target.isDir && !node.isDir:
+			return linkErr(errIsDir)
+		case !target.isDir && node.isDir:
+			return linkErr(errNotDir)
+		case target.isDir && len(m.children(newAbs)) > 0:
+			return linkErr(errNotEmpty)
+		}
+	}
+	if err := m.checkParent("rename", newpath, newAbs); err != nil {
+		return linkErr(err.(*fs.PathError).Err)
+	}
+	var keys []string
+	for key := range m.nodes {
+		if isInside(key, oldAbs) {
+			keys = append(keys, key)
+		}
+	}
+	for _, key := range keys {
+		m.nodes[newAbs+key[len(oldAbs):]] = m.nodes[key]
+		delete(m.nodes, key)
+	}
+	delete(m.nodes, oldAbs)
+	m.nodes[newAbs] = node
+	return nil
+}
+
+func (m *Mem) Remove(name string) error {
+	path, err := m.abs("remove", name)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.nodes[path]; !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	if len(m.children(path)) > 0 {
+		return &fs.PathError{Op: "remove", Path: name, Err: errNotEmpty}
+	}
+	delete(m.nodes, path)
+	return nil
+}
+
+// RemoveAll 与 os.RemoveAll 一样，name 不存在时不返回错误。
+func (m *Mem) RemoveAll(name string) error {
+	path, err := m.abs("removeall", name)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key := range m.nodes {
+		if key == path || isInside(key, path) {
+			delete(m.nodes, key)
+		}
+	}
+	return nil
+}
+
+func (m *Mem) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	path, err := m.abs("chtimes", name)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	node, ok := m.nodes[path]
+	if !ok {
+		return &fs.PathError{Op: "chtimes", Path: name, Err: fs.ErrNotExist}
+	}
+	node.modTime = mtime
+	return nil
+}
+
+// info 返回 node 的 fs.FileInfo, path 是 node 的绝对路径。
+func (node *memNode) info(path string) fs.FileInfo {
+	mode := fs.FileMode(0644)
+	if node.isDir {
+		mode = fs.ModeDir | 0755
+	}
+	return &memInfo{
+		name:    filepath.Base(path),
+		size:    int64(len(node.data)),
+		mode:    mode,
+		modTime: node.modTime,
+	}
+}
+
+// memInfo 实现了 fs.FileInfo 接口。
+type memInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (info *memInfo) Name() string       { return info.name }
+func (info *memInfo) Size() int64        { return info.size }
+func (info *memInfo) Mode() fs.FileMode  { return info.mode }
+func (info *memInfo) ModTime() time.Time { return info.modTime }
+func (info *memInfo) IsDir() bool        { return info.mode.IsDir() }
+func (info *memInfo) Sys() interface{}   { return nil }
+
+// memReader 是用 Mem.Open 打开的文件，读取的是打开时的内容。
+type memReader struct {
+	info fs.FileInfo
+	*bytes.Reader
+}
+
+func (f *memReader) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *memReader) Close() error               { return nil }
+
+// memWriter 是用 Mem.Create 新建的文件。
+type memWriter struct {
+	mem  *Mem
+	node *memNode
+}
+
+func (f *memWriter) Write(p []byte) (int, error) {
+	f.mem.mu.Lock()
+	defer f.mem.mu.Unlock()
+	f.node.data = append(f.node.data, p...)
+	f.node.modTime = time.Now()
+	return len(p), nil
+}
+
+func (f *memWriter) Sync() error  { return nil }
+func (f *memWriter) Close() error { return nil }
@@ -0,0 +1,243 @@
+package filesys
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newMemTree 新建一个 Mem, tree 的 key 是绝对路径，值为 "/" 表示文件夹，否则是文件内容。
+func newMemTree(t *testing.T, tree map[string]string) *Mem {
+	t.Helper()
+	m := NewMem()
+	for name, content := range tree {
+		if err := MkdirAll(m, filepath.Dir(name)); err != nil {
+			t.Fatal(err)
+		}
+		if content == "/" {
+			if err := MkdirAll(m, name); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		f, err := m.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return m
+}
+
+// memTree 返回 m 里的全部文件，格式与 newMemTree 的 tree 相同（不包括根目录）。
+func memTree(t *testing.T, m *Mem) map[string]string {
+	t.Helper()
+	tree := make(map[string]string)
+	err := m.WalkDir("/", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		switch {
+		case name == "/":
+		case d.IsDir():
+			tree[name] = "/"
+		default:
+			data, err := ReadFile(m, name)
+			if err != nil {
+				return err
+			}
+			tree[name] = string(data)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+func TestMem(t *testing.T) {
+	before := map[string]string{
+		"/a":        "/",
+		"/a/1.txt":  "one",
+		"/a/sub":    "/",
+		"/a/sub/2":  "two",
+		"/b":        "/",
+		"/file.txt": "file",
+	}
+	tests := []struct {
+		name    string
+		op      func(m *Mem) error
+		wantErr error // 与 errors.Is 对比，nil 表示不应出错
+		want    map[string]string
+	}{
+		{
+			name: "mkdir",
+			op:   func(m *Mem) error { return m.Mkdir("/b/new", 0755) },
+			want: map[string]string{"/b/new": "/"},
+		},
+		{
+			name:    "mkdir existing",
+			op:      func(m *Mem) error { return m.Mkdir("/a", 0755) },
+			wantErr: fs.ErrExist,
+		},
+		{
+			name:    "mkdir without parent",
+			op:      func(m *Mem) error { return m.Mkdir("/x/y", 0755) },
+			wantErr: fs.ErrNotExist,
+		},
+		{
+			name:    "mkdir in a file",
+			op:      func(m *Mem) error { return m.Mkdir("/file.txt/y", 0755) },
+			wantErr: errNotDir,
+		},
+		{
+			name: "create overwrites",
+			op:   func(m *Mem) error { return CopyFile(m, "/file.txt", "/a/1.txt") },
+			want: map[string]string{"/file.txt": "one"},
+		},
+		{
+			name:    "create a folder",
+			op:      func(m *Mem) error { _, err := m.Create("/a"); return err },
+			wantErr: errIsDir,
+		},
+		{
+			name: "rename a folder with its contents",
+			op:   func(m *Mem) error { return m.Rename("/a", "/c") },
+			want: map[string]string{
+				"/a": "", "/a/1.txt": "", "/a/sub": "", "/a/sub/2": "",
+				"/c": "/", "/c/1.txt": "one", "/c/sub": "/", "/c/sub/2": "two",
+			},
+		},
+		{
+			name: "rename onto an empty folder",
+			op:   func(m *Mem) error { return m.Rename("/a/sub", "/b") },
+			want: map[string]string{"/a/sub": "", "/a/sub/2": "", "/b/2": "two"},
+		},
+		{
+			name:    "rename onto a non-empty folder",
+			op:      func(m *Mem) error { return m.Rename("/b", "/a") },
+			wantErr: errNotEmpty,
+		},
+		{
+			name:    "rename a folder into itself",
+			op:      func(m *Mem) error { return m.Rename("/a", "/a/sub/a") },
+			wantErr: fs.ErrInvalid,
+		},
+		{
+			name:    "rename a file onto a folder",
+			op:      func(m *Mem) error { return m.Rename("/file.txt", "/b") },
+			wantErr: errIsDir,
+		},
+		{
+			name: "remove a file",
+			op:   func(m *Mem) error { return m.Remove("/a/1.txt") },
+			want: map[string]string{"/a/1.txt": ""},
+		},
+		{
+			name:    "remove a non-empty folder",
+			op:      func(m *Mem) error { return m.Remove("/a") },
+			wantErr: errNotEmpty,
+		},
+		{
+			name:    "remove a missing file",
+			op:      func(m *Mem) error { return m.Remove("/missing") },
+			wantErr: fs.ErrNotExist,
+		},
+		{
+			name: "remove all",
+			op:   func(m *Mem) error { return m.RemoveAll("/a") },
+			want: map[string]string{"/a": "", "/a/1.txt": "", "/a/sub": "", "/a/sub/2": ""},
+		},
+		{
+			name: "remove all of a missing file",
+			op:   func(m *Mem) error { return m.RemoveAll("/missing") },
+		},
+		{
+			name:    "chtimes of a missing file",
+			op:      func(m *Mem) error { return m.Chtimes("/missing", time.Now(), time.Now()) },
+			wantErr: fs.ErrNotExist,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMemTree(t, before)
+			err := tt.op(m)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+				if got := memTree(t, m); !reflect.DeepEqual(got, before) {
+					t.Errorf("files changed after a failed operation: %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			// want 只列出有变化的文件，值为空字符串表示该文件已不存在。
+			want := make(map[string]string)
+			for k, v := range before {
+				want[k] = v
+			}
+			for k, v := range tt.want {
+				if v == "" {
+					delete(want, k)
+				} else {
+					want[k] = v
+				}
+			}
+			if got := memTree(t, m); !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestMemWalkDir(t *testing.T) {
+	m := newMemTree(t, map[string]string{
+		"/r/b":   "/",
+		"/r/b/2": "2",
+		"/r/a":   "1",
+		"/r/c":   "3",
+	})
+	tests := []struct {
+		name string
+		skip string // 遇到该文件夹时返回 fs.SkipDir
+		want []string
+	}{
+		{"all", "", []string{"/r", "/r/a", "/r/b", "/r/b/2", "/r/c"}},
+		{"skip dir", "/r/b", []string{"/r", "/r/a", "/r/b", "/r/c"}},
+		{"skip root", "/r", []string{"/r"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		err := m.WalkDir("/r", func(name string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			got = append(got, name)
+			if name == tt.skip {
+				return fs.SkipDir
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	err := m.WalkDir("/missing", func(name string, d fs.DirEntry, err error) error { return err })
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("walking a missing folder: got %v, want fs.ErrNotExist", err)
+	}
+}
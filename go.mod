@@ -3,8 +3,9 @@ module github.com/ahui2016/gof
 go 1.17
 
 require (
+	github.com/BurntSushi/toml v1.2.1
 	golang.org/x/crypto v0.0.0-20211202192323-5770296d904e
+	golang.org/x/sys v0.0.0-20210615035016-665e8c7367d1
 	gopkg.in/yaml.v2 v2.4.0
+	gopkg.in/yaml.v3 v3.0.1
 )
-
-require golang.org/x/sys v0.0.0-20210615035016-665e8c7367d1 // indirect
@@ -1,16 +1,17 @@
 package main
 
 import (
+	"bufio"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/ahui2016/gof/model"
 	"github.com/ahui2016/gof/recipes"
 	"github.com/ahui2016/gof/util"
-	"gopkg.in/yaml.v2"
 )
 
 // 需要使用哪些 recipe, 要先在这里注册。
@@ -30,8 +31,17 @@ var tasks model.Tasks
 var (
 	showVer = flag.Bool("v", false, "the version of gof")
 
-	// YAML 文件名
-	config = flag.String("f", "", "use a YAML config file")
+	// 任务文件名 (YAML, JSON 或 TOML)
+	config = flag.String("f", "", "use a YAML (or JSON, TOML) config file")
+
+	// -dump 的输出格式（默认输出 YAML）。任务文件的格式根据文件名后缀判断，无法判断时才采用 -format.
+	format = flag.String("format", "", "the format of -dump (and of the config file without a known extension): yaml, json or toml")
+
+	// 任务文件里出现未知的项目时报错
+	strict = flag.Bool("strict", false, "reject unknown keys in the config file")
+
+	// 采用 YAML 文件里的某个 profile
+	profile = flag.String("profile", "", "use a profile in the YAML config file")
 
 	// -r 的优先级高于 -f (即，如果指定了 -r, 就忽略 -f)
 	recipe = flag.String("r", "", "use a recipe with default options")
@@ -40,29 +50,77 @@ var (
 
 	dump = flag.Bool("dump", false, "do not run tasks, but print messages")
 
+	// 只检查任务，显示警告信息，不执行任何操作
+	check = flag.Bool("check", false, "do not run tasks, but validate them and print warnings")
+
+	// 从文件（或 stdin）读取文件名，追加到命令行输入的文件名之后
+	namesFrom = flag.String("names-from", "", "read filenames from a file, use - for stdin")
+	nulSep    = flag.Bool("0", false, "use NUL instead of newline as the separator of -names-from and -print-names")
+
+	// 只输出任务处理过（或 dry run 时将要处理）的文件名，不输出其它信息
+	printNames = flag.Bool("print-names", false, "print the names of the files that tasks act on, without other messages")
+
+	// 执行任务前显示将要执行的操作并请使用者确认
+	interactive    = flag.Bool("i", false, "show the operations of each task and ask for confirmation before running it")
+	confirmDeletes = flag.Int("confirm-deletes", 100, "ask for confirmation if a task will delete more files than this (0 for off)")
+	assumeYes      = flag.Bool("yes", false, "do not ask for confirmation (for scripts)")
+
+	// gof plan 保存执行计划的文件名，以及 gof apply 发现文件有变化时是否重新生成计划
+	planOut = flag.String("out", "", "the file to save the plan of gof plan")
+	replan  = flag.Bool("replan", false, "let gof apply plan again if files have changed since gof plan")
+
 	// filenames, 优先级高于 YAML 文件里的 names
 	names []string
 )
 
 func init() {
 	util.Panic(initRecipes())
+	if initCommand() {
+		return
+	}
 	initFlag()
 }
 
 func initFlag() {
 	flag.Parse()
+	model.Strict = *strict
 	names = flag.Args()
 
-	// 如果有 "-v" 或 "-list" 或 "-help", 则显示相关信息，并且忽略其它参数，不执行任何操作。
-	if *showVer || *list {
+	// 如果有 "-v" 则显示相关信息，并且忽略其它参数，不执行任何操作。
+	if *showVer {
+		return
+	}
+	// 如果有 "-list", 则只需要加载 YAML 文件里定义的 recipe (macros).
+	if *list {
+		loadMacros()
 		return
 	}
+	initTasks()
+}
+
+// loadMacros 如果指定了 YAML 文件，则加载该文件里定义的 recipe (macros).
+func loadMacros() {
+	if *config != "" {
+		_, err := model.LoadTasks(*config, *format, *profile)
+		util.Panic(err)
+	}
+}
+
+// initTasks 根据命令行参数 (-r 或 -f, 以及 -profile, -names-from, 文件名等) 初始化 tasks.
+func initTasks() {
+	if *namesFrom != "" {
+		more, err := util.ReadNames(*namesFrom, *nulSep)
+		util.Panic(err)
+		names = append(names, more...)
+	}
 
-	// 如果命令行指定了 recipe 名称，则不需要 YAML 文件
+	// 如果命令行指定了 recipe 名称，则不需要 YAML 文件,
+	// 但如果同时指定了 YAML 文件，则会加载该文件里定义的 recipe (macros).
 	if *recipe != "" {
+		loadMacros()
 		v := getRecipe(*recipe)
 		tasks = model.Tasks{AllTasks: []model.Task{{
-			Recipe:  *recipe,
+			Recipe:  v.Name(),
 			Options: v.Default(),
 			Names:   names,
 		}}}
@@ -71,25 +129,41 @@ func initFlag() {
 		if strings.TrimSpace(*config) == "" {
 			log.Fatalf("\nUsage Example:\n    gof -f example.yaml\n    gof -r swap file1 file2")
 		}
-		tasksFile, err := os.ReadFile(*config)
+		var err error
+		tasks, err = model.LoadTasks(*config, *format, *profile)
 		util.Panic(err)
-		util.Panic(yaml.Unmarshal(tasksFile, &tasks))
 	}
 
 	// 命令行输入的文件名的优先级比 tasks.Namse 更高。
 	if len(names) > 0 {
 		tasks.Names = names
+		// 命令行输入的文件名总是相对于当前文件夹
+		if tasks.PathsRelativeTo == model.RelativeToYAML {
+			for i := range tasks.Names {
+				abs, err := filepath.Abs(tasks.Names[i])
+				util.Panic(err)
+				tasks.Names[i] = abs
+			}
+		}
 	}
 
 	// tasks.Names 的优先级比单个 task 里的 Names 更高。
 	if len(tasks.Names) > 0 {
 		for i := range tasks.AllTasks {
 			tasks.AllTasks[i].Names = nil
+			tasks.AllTasks[i].NamesFile = ""
 		}
 	}
 }
 
 func main() {
+	if subcommand != nil {
+		if err := subcommand.run(); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// 如果有 "-v" 或 "-list" 或 "-help", 则显示相关信息，并且忽略其它参数，不执行任何操作。
 	if *showVer {
 		fmt.Printf("gof %s\n", gofVer)
@@ -97,13 +171,18 @@ func main() {
 		return
 	}
 	if *list {
-		recipesNames := []string{}
-		for k := range recipes.Get {
-			recipesNames = append(recipesNames, k)
+		fmt.Println("registered recipes:")
+		for _, name := range recipes.Names() {
+			line := "  " + name
+			aliases, deprecated := recipes.AliasesOf(name)
+			if len(aliases) > 0 {
+				line += fmt.Sprintf(" (alias: %s)", strings.Join(aliases, ", "))
+			}
+			if len(deprecated) > 0 {
+				line += fmt.Sprintf(" (deprecated: %s)", strings.Join(deprecated, ", "))
+			}
+			fmt.Println(line)
 		}
-		fmt.Print("registered recipes: ")
-		fmt.Print(strings.Join(recipesNames, ", "))
-		fmt.Println()
 		return
 	}
 	if *help {
@@ -121,13 +200,37 @@ func main() {
 	if *dump {
 		util.Panic(printDump(tasks))
 	}
-	if err := tasks.ExecAll(!*dump); err != nil {
+	if *check {
+		for _, warning := range tasks.Check() {
+			log.Print("warning: ", warning)
+		}
+	} else {
+		for _, warning := range tasks.Warnings() {
+			log.Print("warning: ", warning)
+		}
+	}
+	if !*assumeYes && (*interactive || *confirmDeletes > 0) {
+		tasks.Confirmer = &confirmer{
+			interactive: *interactive,
+			maxDeletes:  *confirmDeletes,
+			in:          bufio.NewReader(os.Stdin),
+			out:         os.Stderr,
+		}
+	}
+	if *printNames {
+		util.Panic(redirectStdout())
+	}
+	if err := tasks.ExecAll(!*dump && !*check); err != nil {
 		log.Fatal(err)
 	}
 }
 
 func printDump(in interface{}) error {
-	blob, err := yaml.Marshal(&in)
+	dumpFormat := *format
+	if dumpFormat == "" {
+		dumpFormat = model.FormatYAML
+	}
+	blob, err := model.Marshal(in, dumpFormat)
 	if err != nil {
 		return err
 	}
@@ -135,10 +238,40 @@ func printDump(in interface{}) error {
 	return nil
 }
 
+// redirectStdout 把 tasks 处理过的文件名输出到 stdout, 而 recipe 输出的其它信息则全部丢弃。
+func redirectStdout() error {
+	tasks.NamesOut = os.Stdout
+	tasks.NamesSep = "\n"
+	if *nulSep {
+		tasks.NamesSep = "\x00"
+	}
+	_, err := discardStdout()
+	return err
+}
+
+// discardStdout 把 os.Stdout 换成 os.DevNull, 丢弃之后输出到 stdout 的信息，
+// 返回的 restore 用于恢复原来的 os.Stdout.
+func discardStdout() (restore func(), err error) {
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	stdout := os.Stdout
+	os.Stdout = devNull
+	return func() {
+		os.Stdout = stdout
+		devNull.Close()
+	}, nil
+}
+
 func getRecipe(name string) recipes.Recipe {
-	v, ok := recipes.Get[*recipe]
+	canonical, warning := recipes.Resolve(name)
+	if warning != "" {
+		log.Print("warning: ", warning)
+	}
+	v, ok := recipes.Get[canonical]
 	if !ok {
-		log.Fatalf("not found recipe: %s\nuse -list to list out all registered recipes", *recipe)
+		log.Fatalf("not found recipe: %s\nuse -list to list out all registered recipes", name)
 	}
 	return v
 }
@@ -0,0 +1,74 @@
+package model
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/ahui2016/gof/recipes"
+)
+
+// Confirmer 用于在执行任务前请使用者确认将要执行的操作（参见 Tasks.Confirmer）。
+type Confirmer interface {
+	// ConfirmOps 返回被确认的操作，返回空切片表示跳过该任务。
+	ConfirmOps(task string, ops []recipes.Op) ([]recipes.Op, error)
+
+	// ConfirmTask 用于无法列出具体操作的任务 (recipe 没有实现 recipes.Planner),
+	// 返回 false 表示跳过该任务。
+	ConfirmTask(task string) (bool, error)
+}
+
+// confirmTask 用于无法列出具体操作的任务，经使用者确认后执行，必须在任务的工作目录内执行。
+func (all Tasks) confirmTask(task Task, recipe recipes.Recipe) error {
+	ok, err := all.Confirmer.ConfirmTask(task.title())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		log.Printf("%s: skipped (not confirmed)", task.title())
+		all.state.setChanged(false)
+		return nil
+	}
+	return all.exec(recipe, task.Options)
+}
+
+// execConfirmed 只执行使用者确认了的操作，ops 是任务将要执行的全部操作，必须在任务的工作目录内执行。
+// 注意，如果全部操作都被确认，则会照常执行 recipe, 有些 recipe 会重新遍历文件 (参见 recipes.Planner),
+// 因此可能处理确认之后才出现的文件。
+func (all Tasks) execConfirmed(task Task, recipe recipes.Recipe, ops []recipes.Op) error {
+	if len(ops) == 0 {
+		log.Printf("%s: nothing to do", task.title())
+		all.state.setChanged(false)
+		return nil
+	}
+	confirmed, err := all.Confirmer.ConfirmOps(task.title(), ops)
+	if err != nil {
+		return err
+	}
+	if len(confirmed) == 0 {
+		log.Printf("%s: skipped (not confirmed)", task.title())
+		all.state.setChanged(false)
+		return nil
+	}
+	// 全部操作都被确认时，与平时一样执行 recipe (效果相同，参见 recipes.Planner)。
+	if len(confirmed) == len(ops) {
+		return all.exec(recipe, task.Options)
+	}
+	if err := execOps(all.fs(), task.title(), confirmed); err != nil {
+		return err
+	}
+	all.state.setChanged(true)
+	return all.printOpNames(confirmed)
+}
+
+// printOpNames 把 ops 涉及的文件名写入 all.NamesOut (参见 printNames)。
+func (all Tasks) printOpNames(ops []recipes.Op) error {
+	if all.NamesOut == nil {
+		return nil
+	}
+	for _, op := range ops {
+		if _, err := fmt.Fprint(all.NamesOut, op.Dest, all.NamesSep); err != nil {
+			return err
+		}
+	}
+	return nil
+}
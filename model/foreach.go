@@ -0,0 +1,165 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Foreach 用于对多个项目重复执行同一个任务，在 YAML 里可以是一个列表，也可以是一个 glob 字符串。
+//
+// 如果是列表，列表里的每一项都是一个 map, 在任务的 names, options, workdir, names-file 里
+// 可以用 ${item.key} 引用 map 里的值，例如：
+//
+//	all-tasks:
+//	- recipe: move-new-files
+//	  foreach:
+//	  - {src: ./inbox1/, dest: ./archive1/}
+//	  - {src: ./inbox2/, dest: ./archive2/}
+//	  names: [${item.dest}, ${item.src}]
+//
+// 如果是 glob 字符串（相对于任务的工作目录），则对匹配的每个文件执行一次，
+// ${item} 是文件路径，${item.name} 是文件名，${item.dir} 是文件所在的文件夹。
+type Foreach struct {
+	Items []map[string]string
+	Glob  string
+}
+
+// IsEmpty 在没有设定 foreach 时返回 true.
+func (f *Foreach) IsEmpty() bool {
+	return f == nil || (len(f.Items) == 0 && f.Glob == "")
+}
+
+func (f *Foreach) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if err := unmarshal(&f.Glob); err == nil {
+		return nil
+	}
+	return unmarshal(&f.Items)
+}
+
+func (f Foreach) MarshalYAML() (interface{}, error) {
+	if f.Glob != "" {
+		return f.Glob, nil
+	}
+	return f.Items, nil
+}
+
+func (f *Foreach) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &f.Glob); err == nil {
+		return nil
+	}
+	return json.Unmarshal(data, &f.Items)
+}
+
+func (f Foreach) MarshalJSON() ([]byte, error) {
+	if f.Glob != "" {
+		return json.Marshal(f.Glob)
+	}
+	return json.Marshal(f.Items)
+}
+
+func (f *Foreach) UnmarshalTOML(data interface{}) error {
+	switch v := data.(type) {
+	case string:
+		f.Glob = v
+	case []interface{}:
+		for _, item := range v {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("foreach: item should be a table: %v", item)
+			}
+			strMap := make(map[string]string)
+			for k, v := range m {
+				strMap[k] = fmt.Sprint(v)
+			}
+			f.Items = append(f.Items, strMap)
+		}
+	default:
+		return fmt.Errorf("foreach: should be a string or an array of tables")
+	}
+	return nil
+}
+
+// MarshalTOML 把 Foreach 转换为 TOML 的字符串或 inline table 数组。
+func (f Foreach) MarshalTOML() ([]byte, error) {
+	if f.Glob != "" {
+		return json.Marshal(f.Glob)
+	}
+	var items []string
+	for _, item := range f.Items {
+		var pairs []string
+		for k, v := range item {
+			key, _ := json.Marshal(k)
+			value, _ := json.Marshal(v)
+			pairs = append(pairs, fmt.Sprintf("%s = %s", key, value))
+		}
+		sort.Strings(pairs)
+		items = append(items, "{"+strings.Join(pairs, ", ")+"}")
+	}
+	return []byte("[" + strings.Join(items, ", ") + "]"), nil
+}
+
+// vars 返回每一项对应的变量，用于替换任务里的 ${item.key}.
+// 注意，如果是 glob, 则需要在任务的工作目录内执行。
+func (f Foreach) vars() ([]map[string]string, error) {
+	if f.Glob == "" {
+		all := make([]map[string]string, len(f.Items))
+		for i, item := range f.Items {
+			all[i] = make(map[string]string)
+			for k, v := range item {
+				all[i]["item."+k] = v
+			}
+		}
+		return all, nil
+	}
+	matches, err := filepath.Glob(f.Glob)
+	if err != nil {
+		return nil, err
+	}
+	all := make([]map[string]string, len(matches))
+	for i, match := range matches {
+		all[i] = map[string]string{
+			"item":      match,
+			"item.name": filepath.Base(match),
+			"item.dir":  filepath.Dir(match),
+		}
+	}
+	return all, nil
+}
+
+// execForeach 对 task.Foreach 里的每一项分别检查并执行一次 task.
+func (all Tasks) execForeach(task Task, realRun bool) error {
+	var items []map[string]string
+	err := inDir(all.workdir(task), func() (err error) {
+		items, err = task.Foreach.vars()
+		return
+	})
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		log.Printf("%s: foreach: nothing to do", task.Recipe)
+	}
+	for i, vars := range items {
+		log.Printf("%s: foreach [%d/%d] %s", task.Recipe, i+1, len(items), itemString(vars))
+		each := task.expand(vars)
+		each.Foreach = nil
+		if err := all.execTask(each, realRun); err != nil {
+			return fmt.Errorf("foreach [%d/%d]: %w", i+1, len(items), err)
+		}
+	}
+	return nil
+}
+
+// itemString 返回 foreach 中的一项的简短描述，用于显示执行进度。
+func itemString(vars map[string]string) string {
+	var pairs []string
+	for k, v := range vars {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, " ")
+}
@@ -0,0 +1,92 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// 任务文件的格式
+const (
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+	FormatTOML = "toml"
+)
+
+// FormatOf 根据文件名的后缀判断任务文件的格式，无法判断时返回空字符串。
+func FormatOf(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".json":
+		return FormatJSON
+	case ".toml":
+		return FormatTOML
+	}
+	return ""
+}
+
+// Strict 为 true 时，Unmarshal 遇到未知的项目（比如拼写错误的 key）会返回错误，
+// 默认忽略未知的项目。
+var Strict bool
+
+// Unmarshal 按照 format 格式解析 data.
+func Unmarshal(data []byte, format string, v interface{}) error {
+	switch format {
+	case FormatYAML:
+		if Strict {
+			return yaml.UnmarshalStrict(data, v)
+		}
+		return yaml.Unmarshal(data, v)
+	case FormatJSON:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		if Strict {
+			decoder.DisallowUnknownFields()
+		}
+		return decoder.Decode(v)
+	case FormatTOML:
+		meta, err := toml.Decode(string(data), v)
+		if err != nil || !Strict {
+			return err
+		}
+		for _, key := range meta.Undecoded() {
+			if !insideForeach(key) {
+				return fmt.Errorf("unknown field %q", key.String())
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown format: %s", format)
+}
+
+// insideForeach 判断 key 是否在 foreach 之内。
+// foreach 的内容由 Foreach.UnmarshalTOML 自行解析，toml 无法得知，因此会被当作未解析的 key.
+func insideForeach(key toml.Key) bool {
+	for i := 0; i < len(key)-1; i++ {
+		if key[i] == "foreach" {
+			return true
+		}
+	}
+	return false
+}
+
+// Marshal 把 v 转换为 format 格式。
+func Marshal(v interface{}, format string) ([]byte, error) {
+	switch format {
+	case FormatYAML:
+		return yaml.Marshal(v)
+	case FormatJSON:
+		blob, err := json.MarshalIndent(v, "", "  ")
+		return append(blob, '\n'), err
+	case FormatTOML:
+		var buf bytes.Buffer
+		err := toml.NewEncoder(&buf).Encode(v)
+		return buf.Bytes(), err
+	}
+	return nil, fmt.Errorf("unknown format: %s", format)
+}
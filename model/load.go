@@ -0,0 +1,262 @@
+package model
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ahui2016/gof/recipes"
+	"github.com/ahui2016/gof/util"
+)
+
+// Tasks.PathsRelativeTo 的可选值
+const (
+	RelativeToCwd  = "cwd"
+	RelativeToYAML = "yaml"
+)
+
+// LoadTasks 读取任务文件 name, 并记录其所在的文件夹，以便解析任务里的相对目录。
+// 任务文件可以是 YAML, JSON 或 TOML 格式，优先根据文件名后缀判断（参见 FormatOf），
+// 无法判断时采用 format, 如果 format 也是空字符串则当作 YAML.
+// 如果有 include, 会把被导入的任务展开，返回的 Tasks 不再包含 include 与 vars.
+// 如果 profile 不是空字符串，则采用任务文件里同名的 profile (参见 Profile)。
+func LoadTasks(name, format, profile string) (Tasks, error) {
+	return loadTasks(name, format, profile, nil, nil)
+}
+
+// loadTasks 读取任务文件 name, vars 用于覆盖文件里的 vars,
+// stack 是正在导入的文件 (绝对路径)，用于检查循环导入。
+// 被导入的文件的相对路径总是相对于该文件所在的文件夹，除非它指定了 paths-relative-to: cwd
+// profile 只在最外层的文件里有效。
+func loadTasks(name, format, profile string, vars map[string]string, stack []string) (all Tasks, err error) {
+	name, err = filepath.Abs(name)
+	if err != nil {
+		return
+	}
+	if util.StrIndex(stack, name) >= 0 {
+		err = fmt.Errorf("include cycle: %s -> %s", strings.Join(stack, " -> "), name)
+		return
+	}
+	stack = append(stack, name)
+	included := len(stack) > 1
+
+	tasksFile, err := os.ReadFile(name)
+	if err != nil {
+		return
+	}
+	if ext := FormatOf(name); ext != "" {
+		format = ext
+	} else if format == "" {
+		format = FormatYAML
+	}
+	if err = Unmarshal(tasksFile, format, &all); err != nil {
+		return all, fmt.Errorf("%s: %w", name, err)
+	}
+	if all.Version > SchemaVersion {
+		return all, fmt.Errorf("%s: version %d is not supported, please upgrade gof", name, all.Version)
+	}
+	all.dir = filepath.Dir(name)
+	for k, v := range vars {
+		if all.Vars == nil {
+			all.Vars = make(map[string]string)
+		}
+		all.Vars[k] = v
+	}
+	var p *Profile
+	if !included && profile != "" {
+		if p, err = all.useProfile(profile); err != nil {
+			return
+		}
+	}
+
+	// 先导入其它文件，因为当前文件可能会用到被导入文件里定义的 recipe.
+	var allTasks []Task
+	for _, include := range all.Include {
+		file := absPath(all.dir, util.ExpandVars(include.File, all.Vars))
+		sub, err := loadTasks(file, "", "", include.Vars, stack)
+		if err != nil {
+			return all, err
+		}
+		allTasks = append(allTasks, sub.AllTasks...)
+		all.warnings = append(all.warnings, sub.warnings...)
+		all.Safety = all.Safety.merge(sub.Safety)
+	}
+	all.resolveRecipes(name)
+	all.migrateOptions(name)
+	if err = all.registerMacros(); err != nil {
+		return
+	}
+
+	if p != nil {
+		p.override(all.AllTasks)
+		p.override(allTasks)
+		if err = p.check(append(allTasks, all.AllTasks...)); err != nil {
+			return all, fmt.Errorf("profile %s: %w", profile, err)
+		}
+	}
+	all.applyDefaults(all.AllTasks)
+	all.Safety.resolve(all.dir, all.Vars)
+	all.expandVars()
+	for i := range all.AllTasks {
+		all.AllTasks[i].dir = all.dir
+	}
+	all.convertPaths()
+
+	relativeTo := all.PathsRelativeTo
+	if relativeTo == "" && (included || all.Version >= 2) {
+		relativeTo = RelativeToYAML
+	}
+	switch relativeTo {
+	case "", RelativeToCwd:
+	case RelativeToYAML:
+		all.resolvePaths()
+	default:
+		return all, fmt.Errorf("paths-relative-to: unknown value %q", all.PathsRelativeTo)
+	}
+
+	// 被导入的文件的 global-names 与 workdir 只对该文件里的任务有效。
+	if included {
+		all.flatten()
+	}
+
+	// 被导入的任务已经合并了被导入文件里的 defaults, 在这里再合并当前文件的 defaults.
+	all.applyDefaults(allTasks)
+	all.AllTasks = append(allTasks, all.AllTasks...)
+	all.Include = nil
+	all.Vars = nil
+	all.Defaults = nil
+	all.Profiles = nil
+	return all, nil
+}
+
+// applyDefaults 把 all.Defaults 里的默认 options 合并到 tasks 里（任务里已有的 options 优先）。
+func (all *Tasks) applyDefaults(tasks []Task) {
+	for i := range tasks {
+		defaults, ok := all.Defaults[tasks[i].Recipe]
+		if !ok {
+			continue
+		}
+		if tasks[i].Options == nil {
+			tasks[i].Options = make(recipes.Options)
+		}
+		for k, v := range defaults {
+			if _, ok := tasks[i].Options[k]; !ok {
+				tasks[i].Options[k] = util.ExpandVars(v, all.Vars)
+			}
+		}
+	}
+}
+
+// expandVars 替换 global-names, workdir 以及每个任务里的变量 (参见 Tasks.Vars)。
+func (all *Tasks) expandVars() {
+	if len(all.Vars) == 0 {
+		return
+	}
+	expand := func(s string) string {
+		return util.ExpandVars(s, all.Vars)
+	}
+	all.Workdir = expand(all.Workdir)
+	for i := range all.Names {
+		all.Names[i] = expand(all.Names[i])
+	}
+	for i, task := range all.AllTasks {
+		all.AllTasks[i] = task.expand(all.Vars)
+	}
+}
+
+// flatten 把 global-names 与 workdir 设置到每个任务里，
+// 使得每个任务都能独立存在（被导入其它文件时不受其它文件的影响）。
+func (all *Tasks) flatten() {
+	for i := range all.AllTasks {
+		task := &all.AllTasks[i]
+		task.Workdir = all.workdir(*task)
+		if len(all.Names) > 0 {
+			task.Names = all.Names
+			task.NamesFile = ""
+		}
+	}
+	all.Names = nil
+	all.Workdir = ""
+}
+
+// resolvePaths 把 names, names-file, workdir 以及 recipe 声明的路径 options
+// 都解析为绝对路径（相对于 YAML 文件所在的文件夹，如果指定了 workdir 则相对于 workdir）。
+// 每个任务的 workdir 也会被设为绝对路径，因为有些 recipe (比如 one-way-sync) 需要在源头文件夹内执行。
+func (all *Tasks) resolvePaths() {
+	if all.Workdir != "" {
+		all.Workdir = absPath(all.dir, all.Workdir)
+	}
+	for i, name := range all.Names {
+		all.Names[i] = absPath(all.baseDir(), name)
+	}
+	for i := range all.AllTasks {
+		task := &all.AllTasks[i]
+		task.Workdir = all.workdir(*task)
+		if task.Workdir == "" {
+			task.Workdir = task.dir
+		}
+		for j, name := range task.Names {
+			task.Names[j] = absPath(task.Workdir, name)
+		}
+		if task.NamesFile != "" && task.NamesFile != "-" {
+			task.NamesFile = absPath(task.Workdir, task.NamesFile)
+		}
+		for _, key := range PathOptions(task.Recipe) {
+			if value := task.Options[key]; value != "" {
+				task.Options[key] = absPath(task.Workdir, value)
+			}
+		}
+	}
+}
+
+// convertPaths 对设定了 portable-paths 的任务进行路径转换（参见 util.PortablePath）。
+func (all *Tasks) convertPaths() {
+	if all.PortablePaths {
+		all.Workdir = util.PortablePath(all.Workdir)
+		for i := range all.Names {
+			all.Names[i] = util.PortablePath(all.Names[i])
+		}
+	}
+	for i := range all.AllTasks {
+		task := &all.AllTasks[i]
+		if !all.PortablePaths && !task.PortablePaths {
+			continue
+		}
+		task.Workdir = util.PortablePath(task.Workdir)
+		task.NamesFile = util.PortablePath(task.NamesFile)
+		for j := range task.Names {
+			task.Names[j] = util.PortablePath(task.Names[j])
+		}
+		for _, key := range PathOptions(task.Recipe) {
+			if value, ok := task.Options[key]; ok {
+				task.Options[key] = util.PortablePath(value)
+			}
+		}
+	}
+}
+
+// PathOptions 返回 recipe 声明的路径 options (参见 recipes.PathOptioner)。
+func PathOptions(name string) []string {
+	if v, ok := recipes.Get[name].(recipes.PathOptioner); ok {
+		return v.PathOptions()
+	}
+	return nil
+}
+
+// baseDir 返回 global-names 的相对路径所相对的文件夹。
+func (all *Tasks) baseDir() string {
+	if all.Workdir != "" {
+		return all.Workdir
+	}
+	return all.dir
+}
+
+// absPath 把相对于 dir 的 name 转换为绝对路径。
+// 以变量开头的 name (比如 ${item.src}) 要到执行时才能确定，因此保持原样。
+func absPath(dir, name string) string {
+	if name == "" || filepath.IsAbs(name) || strings.HasPrefix(name, "${") {
+		return name
+	}
+	return filepath.Join(dir, name)
+}
@@ -0,0 +1,195 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ahui2016/gof/filesys"
+	"github.com/ahui2016/gof/recipes"
+	"gopkg.in/yaml.v2"
+)
+
+// Macro 实现了 recipes.Recipe 接口，是在 YAML 里定义的 recipe, 由若干个任务组成。
+// 在 Macro 的任务里，可以用 ${参数名} 引用参数（即 options），用 ${names.0}, ${names.1} 等引用文件名，
+// 如果 names 里的某一项是 ${names} 则会被替换为全部文件名。例如：
+//
+//	macros:
+//	- define: backup-photos
+//	  help: 把最新的照片移动到备份文件夹
+//	  params:
+//	    n: "10"
+//	    dry-run: "yes"
+//	  tasks:
+//	  - recipe: move-new-files
+//	    options:
+//	      n: ${n}
+//	      suffix: .jpg
+//	      dry-run: ${dry-run}
+//	    names:
+//	    - ${names.0}
+//	    - ${names.1}
+type Macro struct {
+	Define      string            `json:"define" toml:"define"` // recipe 的名称
+	Description string            `yaml:"help,omitempty" json:"help,omitempty" toml:"help,omitempty"`
+	Params      map[string]string `yaml:"params,omitempty" json:"params,omitempty" toml:"params,omitempty"` // 参数及其默认值
+	Tasks       []Task            `json:"tasks" toml:"tasks"`
+
+	names   []string
+	options recipes.Options
+	tasks   []Task // 替换了参数之后的任务
+	out     []string
+	fsys    filesys.FS // 由框架注入，传给每个任务 (参见 recipes.FSSetter)
+
+	// 执行 Macro 的 Tasks 的安全设定与 Confirmer, 每个任务都会各自进行安全检查与确认 (参见 Tasks.execSafely)。
+	safety    *Safety
+	confirmer Confirmer
+}
+
+func (m *Macro) Name() string {
+	return m.Define
+}
+
+func (m *Macro) Help() string {
+	blob, err := yaml.Marshal(m)
+	if err != nil {
+		return m.Description
+	}
+	return fmt.Sprintf("\n# %s\n# (defined in YAML)\n\n%s", m.Description, blob)
+}
+
+func (m *Macro) Refresh() {
+	m.names = nil
+	m.options = nil
+	m.tasks = nil
+	m.out = nil
+	m.fsys = nil
+	m.safety = nil
+	m.confirmer = nil
+}
+
+// SetFS 记录注入的 fsys, 每个任务都会采用它访问文件系统。
+func (m *Macro) SetFS(fsys filesys.FS) {
+	m.fsys = fsys
+}
+
+func (m *Macro) Default() recipes.Options {
+	options := make(recipes.Options)
+	for k, v := range m.Params {
+		options[k] = v
+	}
+	return options
+}
+
+// Prepare 用 options 覆盖参数的默认值，并替换每个任务里的参数。
+func (m *Macro) Prepare(names []string, options recipes.Options) {
+	m.names = names
+	m.options = m.Default()
+	for k, v := range options {
+		m.options[k] = v
+	}
+	vars := make(map[string]string)
+	for k, v := range m.options {
+		vars[k] = v
+	}
+	for i, name := range names {
+		vars["names."+strconv.Itoa(i)] = name
+	}
+	for _, task := range m.Tasks {
+		m.tasks = append(m.tasks, m.expand(task, vars))
+	}
+}
+
+// expand 替换 task 里的参数，如果 names 里的某一项是 ${names} 则替换为全部文件名。
+func (m *Macro) expand(task Task, vars map[string]string) Task {
+	var names []string
+	for _, name := range task.Names {
+		if strings.TrimSpace(name) == "${names}" {
+			names = append(names, m.names...)
+			continue
+		}
+		names = append(names, name)
+	}
+	task.Names = names
+	return task.expand(vars)
+}
+
+// Validate 依次检查每个任务。
+func (m *Macro) Validate() error {
+	if len(m.tasks) == 0 {
+		return fmt.Errorf("%s: no task", m.Name())
+	}
+	for _, task := range m.tasks {
+		if err := (&Tasks{FS: m.fsys}).execTask(task, false); err != nil {
+			return fmt.Errorf("%s: %w", m.Name(), err)
+		}
+	}
+	return nil
+}
+
+// Exec 依次执行每个任务，每个任务都会各自进行安全检查与确认。
+func (m *Macro) Exec() error {
+	sub := Tasks{FS: m.fsys, Safety: m.safety, Confirmer: m.confirmer, onExec: func(recipe recipes.Recipe) {
+		if lister, ok := recipe.(recipes.Lister); ok {
+			m.out = append(m.out, lister.Affected()...)
+		}
+	}}
+	for _, task := range m.tasks {
+		if err := sub.execTask(task, true); err != nil {
+			return fmt.Errorf("%s: %w", m.Name(), err)
+		}
+	}
+	return nil
+}
+
+// Plan 依次返回每个任务将要执行的操作（路径都是绝对路径），每个任务的操作都要通过安全检查。
+// 设为 dry run 的任务（根据展开参数后的 options 判断）会被跳过。
+// 注意，每个任务的操作都是根据当前的文件生成的，不会考虑前面的任务对文件的修改。
+func (m *Macro) Plan() (ops []recipes.Op, err error) {
+	sub := Tasks{FS: m.fsys, onValidate: func(task Task, recipe recipes.Recipe) error {
+		// 设为 dry run 的任务不会修改文件，因此没有操作 (gof apply 也就不会执行它)。
+		if isDryRunTask(task, recipe) {
+			return nil
+		}
+		more, err := planOps(recipe)
+		if err != nil {
+			return err
+		}
+		if err := m.safety.check(m.fsys, recipe, more); err != nil {
+			return err
+		}
+		ops = append(ops, more...)
+		return nil
+	}}
+	for _, task := range m.tasks {
+		if err := sub.execTask(task, false); err != nil {
+			return nil, fmt.Errorf("%s: %w", m.Name(), err)
+		}
+	}
+	return ops, nil
+}
+
+// Affected 返回每个任务处理过的文件。
+func (m *Macro) Affected() []string {
+	return m.out
+}
+
+// registerMacros 把在 YAML 里定义的 recipe 注册到 recipes.Get 中。
+func (all *Tasks) registerMacros() error {
+	for _, macro := range all.Macros {
+		if macro.Define == "" {
+			return fmt.Errorf("macros: define is empty")
+		}
+		for i, task := range macro.Tasks {
+			if _, ok := recipes.Lookup(task.Recipe); !ok {
+				return fmt.Errorf("%s: not found recipe: %s", macro.Define, task.Recipe)
+			}
+			macro.Tasks[i].dir = all.dir
+		}
+		if err := recipes.Register(macro); err != nil {
+			return err
+		}
+	}
+	all.Macros = nil
+	return nil
+}
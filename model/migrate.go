@@ -0,0 +1,228 @@
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"github.com/ahui2016/gof/recipes"
+	yaml3 "gopkg.in/yaml.v3"
+)
+
+// optionChanges 返回 recipe 声明的已改名或已删除的 options (参见 recipes.Migrator)。
+func optionChanges(name string) []recipes.OptionChange {
+	if v, ok := recipes.Get[name].(recipes.Migrator); ok {
+		return v.OptionChanges()
+	}
+	return nil
+}
+
+// resolveRecipes 把任务里的 recipe 别名转换为 recipe 名称，
+// 如果使用了已弃用的名称则记录警告。name 是任务文件名，用于警告信息。
+func (all *Tasks) resolveRecipes(name string) {
+	resolve := func(where, recipe string) string {
+		canonical, warning := recipes.Resolve(recipe)
+		if warning != "" {
+			all.warnings = append(all.warnings, fmt.Sprintf("%s: %s: %s", name, where, warning))
+		}
+		return canonical
+	}
+	for i, task := range all.AllTasks {
+		all.AllTasks[i].Recipe = resolve(fmt.Sprintf("task %d", i+1), task.Recipe)
+	}
+	for recipe, options := range all.Defaults {
+		if canonical := resolve("defaults", recipe); canonical != recipe {
+			delete(all.Defaults, recipe)
+			all.Defaults[canonical] = options
+		}
+	}
+	for _, macro := range all.Macros {
+		for i, task := range macro.Tasks {
+			macro.Tasks[i].Recipe = resolve("macro "+macro.Define, task.Recipe)
+		}
+	}
+}
+
+// migrateOptions 把已改名的 options 转换为新的名称，删除已删除的 options, 并记录警告。
+// name 是任务文件名，用于警告信息。
+func (all *Tasks) migrateOptions(name string) {
+	migrate := func(where, recipe string, options recipes.Options) {
+		for _, change := range optionChanges(recipe) {
+			value, ok := options[change.Old]
+			if !ok {
+				continue
+			}
+			delete(options, change.Old)
+			msg := fmt.Sprintf("%s: %s: %s: option %q", name, where, recipe, change.Old)
+			if change.New == "" {
+				msg += " has been removed"
+			} else {
+				if _, exists := options[change.New]; !exists {
+					options[change.New] = value
+				}
+				msg += fmt.Sprintf(" has been renamed to %q", change.New)
+			}
+			if change.Note != "" {
+				msg += " (" + change.Note + ")"
+			}
+			all.warnings = append(all.warnings, msg+", use gof migrate to update the file")
+		}
+	}
+	for i, task := range all.AllTasks {
+		migrate(fmt.Sprintf("task %d", i+1), task.Recipe, task.Options)
+	}
+	for recipe, options := range all.Defaults {
+		migrate("defaults", recipe, options)
+	}
+	for _, macro := range all.Macros {
+		for _, task := range macro.Tasks {
+			migrate("macro "+macro.Define, task.Recipe, task.Options)
+		}
+	}
+}
+
+// Migrate 把任务文件的内容 data 更新为当前版本 (SchemaVersion), 返回更新后的内容与警告信息。
+// 如果是 YAML 文件，会尽量保留注释；如果是 JSON 或 TOML 文件，则会重新生成文件内容。
+func Migrate(data []byte, format string) ([]byte, []string, error) {
+	if format == FormatYAML {
+		return migrateYAML(data)
+	}
+	var all Tasks
+	if err := Unmarshal(data, format, &all); err != nil {
+		return nil, nil, err
+	}
+	if all.Version < 2 && all.PathsRelativeTo == "" {
+		all.PathsRelativeTo = RelativeToCwd
+	}
+	if all.Version < SchemaVersion {
+		all.Version = SchemaVersion
+	}
+	all.resolveRecipes("")
+	all.migrateOptions("")
+	blob, err := Marshal(all, format)
+	return blob, all.warnings, err
+}
+
+// migrateYAML 直接修改 YAML 的节点，以便保留注释。
+func migrateYAML(data []byte) ([]byte, []string, error) {
+	var doc yaml3.Node
+	if err := yaml3.Unmarshal(data, &doc); err != nil {
+		return nil, nil, err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml3.MappingNode {
+		return nil, nil, fmt.Errorf("the task file should be a mapping")
+	}
+	root := doc.Content[0]
+	version := 1
+	if node := MapGet(root, "version"); node != nil {
+		v, err := strconv.Atoi(node.Value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("version: %w", err)
+		}
+		version = v
+	}
+	if version > SchemaVersion {
+		return nil, nil, fmt.Errorf("version %d is not supported, please upgrade gof", version)
+	}
+
+	var warnings []string
+	migrateTask := func(where string, task *yaml3.Node) {
+		recipe := MapGet(task, "recipe")
+		if recipe == nil {
+			return
+		}
+		if canonical, warning := recipes.Resolve(recipe.Value); warning != "" {
+			recipe.Value = canonical
+			warnings = append(warnings, fmt.Sprintf("%s: %s", where, warning))
+		}
+		warnings = append(warnings, migrateOptionsNode(where, recipe.Value, MapGet(task, "options"))...)
+	}
+	if tasks := MapGet(root, "all-tasks"); tasks != nil {
+		for i, task := range tasks.Content {
+			migrateTask(fmt.Sprintf("task %d", i+1), task)
+		}
+	}
+	if macros := MapGet(root, "macros"); macros != nil {
+		for _, macro := range macros.Content {
+			if tasks := MapGet(macro, "tasks"); tasks != nil {
+				for _, task := range tasks.Content {
+					migrateTask("macros", task)
+				}
+			}
+		}
+	}
+	if defaults := MapGet(root, "defaults"); defaults != nil && defaults.Kind == yaml3.MappingNode {
+		for i := 0; i+1 < len(defaults.Content); i += 2 {
+			if canonical, warning := recipes.Resolve(defaults.Content[i].Value); warning != "" {
+				defaults.Content[i].Value = canonical
+				warnings = append(warnings, "defaults: "+warning)
+			}
+			recipe := defaults.Content[i].Value
+			warnings = append(warnings, migrateOptionsNode("defaults", recipe, defaults.Content[i+1])...)
+		}
+	}
+
+	// 版本 2 改变了 paths-relative-to 的默认值，因此旧文件需要明确设定 paths-relative-to: cwd
+	if version < 2 && MapGet(root, "paths-relative-to") == nil {
+		mapPrepend(root, "paths-relative-to", RelativeToCwd)
+	}
+	if node := MapGet(root, "version"); node != nil {
+		node.Value = strconv.Itoa(SchemaVersion)
+	} else {
+		mapPrepend(root, "version", strconv.Itoa(SchemaVersion))
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml3.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), warnings, encoder.Close()
+}
+
+// migrateOptionsNode 修改 options 节点里已改名或已删除的 options.
+func migrateOptionsNode(where, recipe string, options *yaml3.Node) (warnings []string) {
+	if options == nil || options.Kind != yaml3.MappingNode {
+		return
+	}
+	for _, change := range optionChanges(recipe) {
+		for i := 0; i+1 < len(options.Content); i += 2 {
+			key := options.Content[i]
+			if key.Value != change.Old {
+				continue
+			}
+			if change.New == "" || MapGet(options, change.New) != nil {
+				options.Content = append(options.Content[:i], options.Content[i+2:]...)
+				warnings = append(warnings, fmt.Sprintf("%s: %s: removed option %q %s", where, recipe, change.Old, change.Note))
+			} else {
+				key.Value = change.New
+				warnings = append(warnings, fmt.Sprintf("%s: %s: renamed option %q to %q", where, recipe, change.Old, change.New))
+			}
+			break
+		}
+	}
+	return
+}
+
+// MapGet 返回 mapping 节点里 key 对应的值，找不到时返回 nil.
+func MapGet(mapping *yaml3.Node, key string) *yaml3.Node {
+	if mapping == nil || mapping.Kind != yaml3.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// mapPrepend 在 mapping 节点的最前面添加 key: value.
+func mapPrepend(mapping *yaml3.Node, key, value string) {
+	pair := []*yaml3.Node{
+		{Kind: yaml3.ScalarNode, Tag: "!!str", Value: key},
+		{Kind: yaml3.ScalarNode, Value: value},
+	}
+	mapping.Content = append(pair, mapping.Content...)
+}
@@ -0,0 +1,206 @@
+package model
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ahui2016/gof/recipes"
+)
+
+// migrated 是用于测试迁移的 recipe: option "n" 已改名为 "count", "gone" 已删除，
+// 并且 "test-old-name" 是已弃用的名称。
+type migrated struct {
+	recipes.Recipe
+}
+
+func (migrated) Name() string              { return "test-migrated" }
+func (migrated) Aliases() []string         { return nil }
+func (migrated) DeprecatedNames() []string { return []string{"test-old-name"} }
+func (migrated) OptionChanges() []recipes.OptionChange {
+	return []recipes.OptionChange{
+		{Old: "n", New: "count"},
+		{Old: "gone", Note: "no longer needed"},
+	}
+}
+
+func init() {
+	if err := recipes.Register(migrated{}); err != nil {
+		panic(err)
+	}
+}
+
+func TestMigrateOptions(t *testing.T) {
+	tests := []struct {
+		name         string
+		options      recipes.Options
+		want         recipes.Options
+		wantWarnings []string // 每个警告应包含的内容
+	}{
+		{
+			name:    "nothing to migrate",
+			options: recipes.Options{"count": "2", "suffix": ".jpg"},
+			want:    recipes.Options{"count": "2", "suffix": ".jpg"},
+		},
+		{
+			name:         "renamed",
+			options:      recipes.Options{"n": "2", "suffix": ".jpg"},
+			want:         recipes.Options{"count": "2", "suffix": ".jpg"},
+			wantWarnings: []string{`option "n" has been renamed to "count"`},
+		},
+		{
+			name:         "renamed but the new name is set",
+			options:      recipes.Options{"n": "2", "count": "3"},
+			want:         recipes.Options{"count": "3"},
+			wantWarnings: []string{`option "n" has been renamed to "count"`},
+		},
+		{
+			name:         "removed",
+			options:      recipes.Options{"gone": "yes", "n": "1"},
+			want:         recipes.Options{"count": "1"},
+			wantWarnings: []string{`option "n" has been renamed`, `option "gone" has been removed (no longer needed)`},
+		},
+	}
+	for _, tt := range tests {
+		// 同样的 options 分别出现在任务、defaults 与 macro 里，都应被转换。
+		clone := func() recipes.Options {
+			options := make(recipes.Options)
+			for k, v := range tt.options {
+				options[k] = v
+			}
+			return options
+		}
+		all := Tasks{
+			AllTasks: []Task{{Recipe: "test-migrated", Options: clone()}},
+			Defaults: map[string]recipes.Options{"test-migrated": clone()},
+			Macros:   []*Macro{{Define: "m", Tasks: []Task{{Recipe: "test-migrated", Options: clone()}}}},
+		}
+		all.migrateOptions("gof.yaml")
+
+		for _, got := range []recipes.Options{all.AllTasks[0].Options, all.Defaults["test-migrated"], all.Macros[0].Tasks[0].Options} {
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+			}
+		}
+		if len(all.warnings) != 3*len(tt.wantWarnings) {
+			t.Errorf("%s: got warnings %q, want %d", tt.name, all.warnings, 3*len(tt.wantWarnings))
+			continue
+		}
+		for i, warning := range all.warnings {
+			want := tt.wantWarnings[i%len(tt.wantWarnings)]
+			if !strings.HasPrefix(warning, "gof.yaml: ") || !strings.Contains(warning, want) {
+				t.Errorf("%s: got warning %q, want %q", tt.name, warning, want)
+			}
+		}
+	}
+}
+
+func TestMigrateYAML(t *testing.T) {
+	tests := []struct {
+		name         string
+		in           string
+		want         string
+		wantWarnings int
+	}{
+		{
+			name: "version 1",
+			in: `# comment
+
+all-tasks:
+  - recipe: test-migrated
+    options:
+      n: 2 # how many
+`,
+			want: `# comment
+
+version: 2
+paths-relative-to: cwd
+all-tasks:
+  - recipe: test-migrated
+    options:
+      count: 2 # how many
+`,
+			wantWarnings: 1,
+		},
+		{
+			name: "version 2 keeps paths-relative-to",
+			in: `version: 2
+all-tasks:
+  - recipe: test-old-name
+    options:
+      gone: "yes"
+      count: 1
+`,
+			want: `version: 2
+all-tasks:
+  - recipe: test-migrated
+    options:
+      count: 1
+`,
+			wantWarnings: 2,
+		},
+		{
+			name: "renamed option already set",
+			in: `version: 2
+all-tasks:
+  - recipe: test-migrated
+    options:
+      n: 1
+      count: 2
+`,
+			want: `version: 2
+all-tasks:
+  - recipe: test-migrated
+    options:
+      count: 2
+`,
+			wantWarnings: 1,
+		},
+		{
+			name: "defaults and macros",
+			in: `version: 1
+paths-relative-to: yaml
+defaults:
+  test-old-name:
+    n: 3
+macros:
+  - define: m
+    tasks:
+      - recipe: test-migrated
+        options:
+          n: ${n}
+`,
+			want: `version: 2
+paths-relative-to: yaml
+defaults:
+  test-migrated:
+    count: 3
+macros:
+  - define: m
+    tasks:
+      - recipe: test-migrated
+        options:
+          count: ${n}
+`,
+			wantWarnings: 3,
+		},
+	}
+	for _, tt := range tests {
+		got, warnings, err := migrateYAML([]byte(tt.in))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+		if len(warnings) != tt.wantWarnings {
+			t.Errorf("%s: got warnings %q, want %d", tt.name, warnings, tt.wantWarnings)
+		}
+	}
+
+	for _, in := range []string{"version: 99\n", "- a\n", "version: x\n"} {
+		if _, _, err := migrateYAML([]byte(in)); err == nil {
+			t.Errorf("migrateYAML(%q): want an error", in)
+		}
+	}
+}
@@ -2,21 +2,149 @@ package model
 
 import (
 	"fmt"
+	"io"
 	"log"
+	"os"
+	"path/filepath"
+	"strings"
 
+	"github.com/ahui2016/gof/filesys"
 	"github.com/ahui2016/gof/recipes"
+	"github.com/ahui2016/gof/util"
 )
 
 type Task struct {
-	Recipe  string
-	Options map[string]string
-	Names   []string // file/folder names
+	// 任务名称，可省略。用于在 profile 里指定任务。
+	Name string `yaml:"name,omitempty" json:"name,omitempty" toml:"name,omitempty"`
+
+	// 设为 false 时跳过该任务，省略时默认为 true.
+	Enabled *bool `yaml:"enabled,omitempty" json:"enabled,omitempty" toml:"enabled,omitempty"`
+
+	Recipe  string            `json:"recipe" toml:"recipe"`
+	Options map[string]string `json:"options" toml:"options"`
+	Names   []string          `json:"names" toml:"names"` // file/folder names
+
+	// 从文件中读取 file/folder names (每行一个，或以 NUL 分隔), 追加到 Names 之后。
+	// 如果设为 "-" 则从 stdin 读取。
+	NamesFile string `yaml:"names-file,omitempty" json:"names-file,omitempty" toml:"names-file,omitempty"`
+
+	// 执行该任务时的工作目录，优先级比 Tasks.Workdir 更高。
+	// 相对目录是相对于 YAML 文件所在的文件夹。
+	Workdir string `yaml:"workdir,omitempty" json:"workdir,omitempty" toml:"workdir,omitempty"`
+
+	// 设为 true 时，names, names-file, workdir 等路径里的反斜杠在非 Windows 系统中会被转换为正斜杠。
+	PortablePaths bool `yaml:"portable-paths,omitempty" json:"portable-paths,omitempty" toml:"portable-paths,omitempty"`
+
+	// 对列表里的每一项（或 glob 匹配的每个文件）分别执行一次该任务，参见 Foreach.
+	Foreach *Foreach `yaml:"foreach,omitempty" json:"foreach,omitempty" toml:"foreach,omitempty"`
+
+	// 只有满足条件时才执行该任务，否则跳过，参见 When.
+	When *When `yaml:"when,omitempty" json:"when,omitempty" toml:"when,omitempty"`
+
+	dir string // YAML 文件所在的文件夹，用于解析相对目录
+}
+
+// title 返回任务的名称，如果没有名称则返回 recipe 名称。
+func (task Task) title() string {
+	if task.Name != "" {
+		return task.Name
+	}
+	return task.Recipe
+}
+
+// isEnabled 在任务没有被停用时返回 true (参见 Task.Enabled)。
+func (task Task) isEnabled() bool {
+	return task.Enabled == nil || *task.Enabled
+}
+
+// expand 返回一个替换了变量的新任务 (参见 util.ExpandVars)。
+func (task Task) expand(vars map[string]string) Task {
+	expanded := task
+	expanded.Options = make(recipes.Options)
+	for k, v := range task.Options {
+		expanded.Options[k] = util.ExpandVars(v, vars)
+	}
+	expanded.Names = make([]string, len(task.Names))
+	for i, name := range task.Names {
+		expanded.Names[i] = util.ExpandVars(name, vars)
+	}
+	expanded.Workdir = util.ExpandVars(task.Workdir, vars)
+	expanded.NamesFile = util.ExpandVars(task.NamesFile, vars)
+	if task.When != nil {
+		expanded.When = task.When.expand(vars)
+	}
+	return expanded
+}
+
+// Include 表示从另一个 YAML 文件导入任务。
+type Include struct {
+	File string            `json:"file" toml:"file"`                                           // 任务文件名，相对路径是相对于当前文件所在的文件夹
+	Vars map[string]string `yaml:"vars,omitempty" json:"vars,omitempty" toml:"vars,omitempty"` // 覆盖被导入文件里的 vars
 }
 
+// SchemaVersion 是当前任务文件的版本。
+// 版本 2: 默认 paths-relative-to: yaml (之前的版本默认 paths-relative-to: cwd)。
+const SchemaVersion = 2
+
 type Tasks struct {
+	// 任务文件的版本，省略时表示版本 1. 可以用 gof migrate 把旧版本的文件更新为当前版本。
+	Version int `yaml:"version,omitempty" json:"version,omitempty" toml:"version,omitempty"`
+
+	// 从其它 YAML 文件导入任务，被导入的任务排在 all-tasks 之前。
+	// 加载后会被展开为 AllTasks 里的任务，因此 -dump 时不会显示 include.
+	Include []Include `yaml:"include,omitempty" json:"include,omitempty" toml:"include,omitempty"`
+
+	// 每个 recipe 的默认 options, 例如 defaults: {one-way-sync: {delete: "yes"}},
+	// 加载时会被合并到每个任务的 options 里（任务里的 options 优先），因此 -dump 时不会显示 defaults.
+	Defaults map[string]recipes.Options `yaml:"defaults,omitempty" json:"defaults,omitempty" toml:"defaults,omitempty"`
+
+	// 同一个 YAML 文件里的多套设定，用 -profile 选择，参见 Profile.
+	// 加载后就不再需要，因此 -dump 时不会显示 profiles.
+	Profiles map[string]Profile `yaml:"profiles,omitempty" json:"profiles,omitempty" toml:"profiles,omitempty"`
+
+	// 在 YAML 里定义的 recipe (参见 Macro), 加载时会被注册，因此 -dump 时不会显示 macros.
+	Macros []*Macro `yaml:"macros,omitempty" json:"macros,omitempty" toml:"macros,omitempty"`
+
+	// 变量，在 names, options, workdir, names-file 里可以用 ${name} 的形式引用。
+	// 加载时会被替换，因此 -dump 时不会显示 vars.
+	Vars map[string]string `yaml:"vars,omitempty" json:"vars,omitempty" toml:"vars,omitempty"`
+
 	// file/folder names, 优先级比 Task 里的 Names 更高。
-	Names    []string `yaml:"global-names"`
-	AllTasks []Task   `yaml:"all-tasks"`
+	Names    []string `yaml:"global-names" json:"global-names" toml:"global-names"`
+	AllTasks []Task   `yaml:"all-tasks" json:"all-tasks" toml:"all-tasks"`
+
+	// 全部任务的默认工作目录，相对目录是相对于 YAML 文件所在的文件夹。
+	Workdir string `yaml:"workdir,omitempty" json:"workdir,omitempty" toml:"workdir,omitempty"`
+
+	// 设为 "yaml" 时，names 等相对路径是相对于 YAML 文件所在的文件夹（或 workdir）,
+	// 加载时会被解析为绝对路径；设为 "cwd" (默认) 时则是相对于当前文件夹。
+	PathsRelativeTo string `yaml:"paths-relative-to,omitempty" json:"paths-relative-to,omitempty" toml:"paths-relative-to,omitempty"`
+
+	// 设为 true 时，全部任务都采用 portable-paths (参见 Task.PortablePaths)。
+	PortablePaths bool `yaml:"portable-paths,omitempty" json:"portable-paths,omitempty" toml:"portable-paths,omitempty"`
+
+	// 针对删除等危险操作的安全设定，参见 Safety.
+	Safety *Safety `yaml:"safety,omitempty" json:"safety,omitempty" toml:"safety,omitempty"`
+
+	// 如果 NamesOut 不是 nil, 每个任务执行后，都会把它处理过的文件名写入 NamesOut,
+	// 每个文件名之后加上 NamesSep (用于 -print-names)。
+	NamesOut io.Writer `yaml:"-" json:"-" toml:"-"`
+	NamesSep string    `yaml:"-" json:"-" toml:"-"`
+
+	// 如果 Confirmer 不是 nil, 每个任务执行前 (dry run 除外) 都要经过它的确认（用于 -i 等）。
+	Confirmer Confirmer `yaml:"-" json:"-" toml:"-"`
+
+	// recipe 访问文件系统时采用的 FS (参见 recipes.FSSetter), nil 表示 filesys.OS.
+	// 执行 Prepare, Validate, Plan 以及 dry run 时会用 filesys.ReadOnly 包装起来。
+	FS filesys.FS `yaml:"-" json:"-" toml:"-"`
+
+	onExec     func(recipes.Recipe)             // 每个任务执行后调用
+	onValidate func(Task, recipes.Recipe) error // 每个任务检查后调用 (在任务的工作目录内)
+	state      *execState                       // ExecAll 执行过程中的状态
+
+	warnings []string // 加载任务文件时产生的警告
+
+	dir string // YAML 文件所在的文件夹，用于解析相对目录
 }
 
 // ExecAll 当 realRun == true 时依次执行每个任务；
@@ -25,29 +153,245 @@ func (all Tasks) ExecAll(realRun bool) error {
 	if len(all.AllTasks) == 0 {
 		return fmt.Errorf("no task")
 	}
+	all.state = new(execState)
 	for _, task := range all.AllTasks {
-		recipe, ok := recipes.Get[task.Recipe]
+		if !task.isEnabled() {
+			log.Printf("%s: skipped (disabled)", task.title())
+			continue
+		}
+		if err := all.execTask(task, realRun); err != nil {
+			return err
+		}
+	}
+	if realRun {
+		log.Print("all tasks are finished.")
+	} else {
+		log.Print("all tasks are validated.")
+	}
+	return nil
+}
+
+// Warnings 返回加载任务文件时产生的警告，比如使用了已改名或已删除的 option.
+func (all Tasks) Warnings() []string {
+	return all.warnings
+}
+
+// Check 检查全部任务，返回警告信息（不会执行任务，也不会读取文件）。
+// 加载任务文件时产生的警告也包括在内。
+func (all Tasks) Check() (warnings []string) {
+	warnings = append(warnings, all.warnings...)
+	checkNames := func(where string, names []string) {
+		for _, name := range names {
+			if util.HasLiteralSeparator(name) {
+				warnings = append(warnings, fmt.Sprintf(
+					"%s: %q contains a backslash, which is not a path separator on this system (try portable-paths: true)", where, name))
+			}
+		}
+	}
+	checkNames("global-names", all.Names)
+	for i, task := range all.AllTasks {
+		where := fmt.Sprintf("task %d (%s)", i+1, task.Recipe)
+		checkNames(where, append([]string{task.Workdir, task.NamesFile}, task.Names...))
+	}
+	return
+}
+
+// execTask 检查并执行一个任务 (如果 realRun == false 则只检查不执行)。
+func (all Tasks) execTask(task Task, realRun bool) error {
+	if !task.Foreach.IsEmpty() {
+		return all.execForeach(task, realRun)
+	}
+	recipe, ok := recipes.Lookup(task.Recipe)
+	if !ok {
+		return fmt.Errorf("not found recipe: %s", task.Recipe)
+	}
+	if task.When != nil {
+		ok, err := task.When.Check(all.workdir(task), all.state.previousChanged(realRun))
+		if err != nil {
+			return err
+		}
 		if !ok {
-			return fmt.Errorf("not found recipe: %s", task.Recipe)
+			log.Printf("%s: skipped (when: %s)", task.title(), task.When)
+			all.state.setChanged(false)
+			return nil
 		}
+	}
+	return inDir(all.workdir(task), func() error {
 		if len(all.Names) > 0 {
 			task.Names = all.Names
+		} else if task.NamesFile != "" {
+			names, err := util.ReadNames(task.NamesFile, false)
+			if err != nil {
+				return err
+			}
+			task.Names = append(task.Names, names...)
 		}
 		recipe.Refresh()
+		setFS(recipe, filesys.ReadOnly{FS: all.fs()})
+		if m, ok := recipe.(*Macro); ok {
+			m.safety, m.confirmer = all.Safety, all.Confirmer
+		}
 		recipe.Prepare(task.Names, task.Options)
 		if err := recipe.Validate(); err != nil {
 			return err
 		}
-		if realRun {
-			if err := recipe.Exec(); err != nil {
+		if all.onValidate != nil {
+			if err := all.onValidate(task, recipe); err != nil {
 				return err
 			}
 		}
+		if !realRun {
+			return nil
+		}
+		if isDryRun(task.Options) {
+			return all.exec(recipe, task.Options)
+		}
+		return all.execSafely(task, recipe)
+	})
+}
+
+// execSafely 先进行安全检查 (参见 Safety), 如果设定了 Confirmer 则再请使用者确认，然后执行任务。
+// 没有实现 recipes.Planner 的 recipe 无法列出具体操作，因此无法进行安全检查。
+func (all Tasks) execSafely(task Task, recipe recipes.Recipe) error {
+	// Macro 的每个任务都会各自进行安全检查与确认 (参见 Macro.Exec)。
+	if _, ok := recipe.(*Macro); ok {
+		return all.exec(recipe, task.Options)
 	}
-	if realRun {
-		log.Print("all tasks are finished.")
-	} else {
-		log.Print("all tasks are validated.")
+	if _, ok := recipe.(recipes.Planner); !ok {
+		if all.Confirmer != nil {
+			return all.confirmTask(task, recipe)
+		}
+		return all.exec(recipe, task.Options)
+	}
+	ops, err := planOps(recipe)
+	if err != nil {
+		return fmt.Errorf("%s: %w", task.title(), err)
+	}
+	if err := all.Safety.check(all.fs(), recipe, ops); err != nil {
+		return fmt.Errorf("%s: %w", task.title(), err)
+	}
+	if all.Confirmer != nil {
+		return all.execConfirmed(task, recipe, ops)
+	}
+	return all.exec(recipe, task.Options)
+}
+
+// exec 执行已通过检查的 recipe.
+func (all Tasks) exec(recipe recipes.Recipe, options recipes.Options) error {
+	if !isDryRun(options) {
+		setFS(recipe, all.fs())
+	}
+	if err := recipe.Exec(); err != nil {
+		return err
+	}
+	all.state.setChanged(isChanged(recipe, options))
+	if all.onExec != nil {
+		all.onExec(recipe)
+	}
+	return all.printNames(recipe)
+}
+
+// fs 返回 recipe 访问文件系统时采用的 FS.
+func (all Tasks) fs() filesys.FS {
+	if all.FS == nil {
+		return filesys.OS{}
+	}
+	return all.FS
+}
+
+// setFS 给实现了 recipes.FSSetter 的 recipe 注入 fsys.
+func setFS(recipe recipes.Recipe, fsys filesys.FS) {
+	if setter, ok := recipe.(recipes.FSSetter); ok {
+		setter.SetFS(fsys)
+	}
+}
+
+// execState 记录 ExecAll 执行过程中的状态。
+type execState struct {
+	changed bool // 上一个任务是否修改了文件
+}
+
+// previousChanged 返回上一个任务是否修改了文件。
+// 如果只是检查任务 (realRun == false), 则无法得知，总是返回 true.
+func (state *execState) previousChanged(realRun bool) bool {
+	if state == nil || !realRun {
+		return true
+	}
+	return state.changed
+}
+
+func (state *execState) setChanged(changed bool) {
+	if state != nil {
+		state.changed = changed
+	}
+}
+
+// isChanged 判断刚执行完的 recipe 是否修改了文件。
+// dry run 不会修改文件；实现了 recipes.Lister 的 recipe 则根据是否处理过文件来判断；
+// 其它 recipe 则一律认为修改了文件。
+func isChanged(recipe recipes.Recipe, options recipes.Options) bool {
+	if isDryRun(options) {
+		return false
+	}
+	if lister, ok := recipe.(recipes.Lister); ok {
+		return len(lister.Affected()) > 0
+	}
+	return true
+}
+
+// isDryRun 在 options 里的 dry-run 设为 yes 时返回 true.
+func isDryRun(options recipes.Options) bool {
+	return strings.ToLower(options["dry-run"]) == "yes"
+}
+
+// isDryRunTask 判断 task 是否为 dry run.
+// Macro 的 dry-run 只是一个参数，其每个任务根据展开后的 options 各自决定 (参见 Macro.Plan)。
+func isDryRunTask(task Task, recipe recipes.Recipe) bool {
+	if _, ok := recipe.(*Macro); ok {
+		return false
+	}
+	return isDryRun(task.Options)
+}
+
+// workdir 返回 task 的工作目录，返回空字符串表示不需要切换目录。
+func (all Tasks) workdir(task Task) string {
+	dir := task.Workdir
+	if dir == "" {
+		dir = all.Workdir
+	}
+	if dir == "" || filepath.IsAbs(dir) {
+		return dir
+	}
+	return filepath.Join(task.dir, dir)
+}
+
+// inDir 切换到文件夹 dir 执行 fn, 执行后返回原来的文件夹。
+// 如果 dir 是空字符串，则直接在当前文件夹执行 fn.
+func inDir(dir string, fn func() error) error {
+	if dir == "" {
+		return fn()
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if err := os.Chdir(dir); err != nil {
+		return err
+	}
+	return util.WrapErrors(fn(), os.Chdir(cwd))
+}
+
+// printNames 把 recipe 处理过的文件名写入 all.NamesOut.
+// 如果 recipe 没有实现 recipes.Lister 则忽略。
+func (all Tasks) printNames(recipe recipes.Recipe) error {
+	lister, ok := recipe.(recipes.Lister)
+	if all.NamesOut == nil || !ok {
+		return nil
+	}
+	for _, name := range lister.Affected() {
+		if _, err := fmt.Fprint(all.NamesOut, name, all.NamesSep); err != nil {
+			return err
+		}
 	}
 	return nil
 }
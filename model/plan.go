@@ -0,0 +1,319 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ahui2016/gof/filesys"
+	"github.com/ahui2016/gof/recipes"
+	"github.com/ahui2016/gof/util"
+)
+
+// PlanVersion 是计划文件的版本。
+// 版本 2 开始记录文件夹的内容 (参见 FileState.Entries), 因此不再接受版本 1 的计划文件。
+const PlanVersion = 2
+
+// Plan 是执行计划 (gof plan), 记录了每个任务的具体操作，以及这些操作所依赖的文件在生成计划时的状态。
+// 执行计划 (gof apply) 时，每个任务执行前都会检查这些文件是否有变化，因此执行的正是生成计划时看到的操作。
+//
+// 注意，每个任务的计划都是根据生成计划时的文件生成的，如果前一个任务会修改后一个任务依赖的文件，
+// 那么执行后一个任务前就会发现文件有变化，需要重新生成计划 (gof apply -replan)。
+type Plan struct {
+	Version int        `json:"version"`
+	Created time.Time  `json:"created"`
+	Tasks   []TaskPlan `json:"tasks"`
+
+	// 生成计划时的安全设定，执行计划时会再次检查 (参见 Safety)。
+	Safety *Safety `json:"safety,omitempty"`
+}
+
+// TaskPlan 是一个任务的执行计划。使用了 foreach 的任务，每一项都有各自的计划。
+type TaskPlan struct {
+	// 生成计划时的任务（已确定 names, workdir 为绝对路径），用于重新生成计划。
+	Task  Task         `json:"task"`
+	Ops   []recipes.Op `json:"ops"`   // 具体操作，路径都是绝对路径
+	Files []FileState  `json:"files"` // Ops 涉及的文件在生成计划时的状态
+
+	// 生成计划时该任务的 dry-run 是否设为 yes, 如果是则执行计划时会跳过该任务。
+	// Macro 本身不是 dry run, 其每个任务各自决定，设为 dry run 的任务不会产生操作 (参见 Macro.Plan)。
+	DryRun bool `json:"dry-run,omitempty"`
+}
+
+// Title 返回任务的名称，如果没有名称则返回 recipe 名称。
+func (tp TaskPlan) Title() string {
+	return tp.Task.title()
+}
+
+// FileState 记录一个文件的状态，用于检查执行计划的前提条件。
+// 文件夹不记录修改日期（因为会随着其内容的变化而变化），而是记录其中的文件名 (Entries)。
+// 被删除的文件夹里的每个文件与子文件夹也都会被记录 (参见 newTaskPlan)。
+type FileState struct {
+	Path    string   `json:"path"`
+	Exists  bool     `json:"exists"`
+	IsDir   bool     `json:"is-dir,omitempty"`
+	Entries []string `json:"entries,omitempty"`
+	Size    int64    `json:"size,omitempty"`
+	ModTime string   `json:"mtime,omitempty"`
+	Hash    string   `json:"hash,omitempty"`
+}
+
+// stateOf 返回文件 name 当前的状态。
+func stateOf(fsys filesys.FS, name string) (state FileState, err error) {
+	state.Path = name
+	info, err := fsys.Lstat(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return
+	}
+	state.Exists = true
+	state.IsDir = info.IsDir()
+	if state.IsDir {
+		entries, err := fsys.ReadDir(name)
+		if err != nil {
+			return state, err
+		}
+		for _, entry := range entries {
+			state.Entries = append(state.Entries, entry.Name())
+		}
+		return state, nil
+	}
+	state.Size = info.Size()
+	state.ModTime = info.ModTime().UTC().Format(time.RFC3339Nano)
+	if info.Mode().IsRegular() {
+		state.Hash, err = util.FSFileSha256Hex(fsys, name)
+	}
+	return
+}
+
+// diff 对比文件的两个状态，返回变化的描述，没有变化时返回空字符串。
+func (state FileState) diff(now FileState) string {
+	switch {
+	case state.Exists && !now.Exists:
+		return "deleted"
+	case !state.Exists && now.Exists:
+		return "created"
+	case state.IsDir != now.IsDir:
+		return "file type changed"
+	case strings.Join(state.Entries, "\x00") != strings.Join(now.Entries, "\x00"):
+		return "folder contents changed"
+	case state.Size != now.Size:
+		return fmt.Sprintf("size changed (%d -> %d)", state.Size, now.Size)
+	case state.ModTime != now.ModTime:
+		return "modification time changed"
+	case state.Hash != now.Hash:
+		return "content changed"
+	}
+	return ""
+}
+
+// Drift 检查 tp.Files 里的文件是否有变化，返回变化的描述。
+func (tp TaskPlan) Drift() ([]string, error) {
+	return tp.drift(filesys.OS{})
+}
+
+func (tp TaskPlan) drift(fsys filesys.FS) (drift []string, err error) {
+	for _, state := range tp.Files {
+		now, err := stateOf(fsys, state.Path)
+		if err != nil {
+			return nil, err
+		}
+		if d := state.diff(now); d != "" {
+			drift = append(drift, state.Path+": "+d)
+		}
+	}
+	return
+}
+
+// Plan 检查全部任务并生成执行计划，不会修改任何文件。
+// 每个任务的 recipe 都必须实现 recipes.Planner, 并且每个任务都要通过安全检查 (参见 Safety)。
+func (all Tasks) Plan() (plan Plan, err error) {
+	if len(all.AllTasks) == 0 {
+		return plan, fmt.Errorf("no task")
+	}
+	plan = Plan{Version: PlanVersion, Created: time.Now(), Safety: all.Safety}
+	all.onValidate = func(task Task, recipe recipes.Recipe) error {
+		tp, err := newTaskPlan(all.fs(), task, recipe)
+		if err != nil {
+			return err
+		}
+		if err := all.Safety.check(all.fs(), recipe, tp.Ops); err != nil {
+			return fmt.Errorf("%s: %w", task.title(), err)
+		}
+		plan.Tasks = append(plan.Tasks, tp)
+		return nil
+	}
+	for _, task := range all.AllTasks {
+		if !task.isEnabled() {
+			log.Printf("%s: skipped (disabled)", task.title())
+			continue
+		}
+		if err = all.execTask(task, false); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Apply 依次执行计划里的每个任务。每个任务执行前都会检查其依赖的文件是否有变化，
+// 如果有变化则拒绝执行；如果 replan 为 true, 则重新生成该任务的计划并执行。
+// 无论是否重新生成计划，执行前都会按照 plan.Safety 进行安全检查，因为计划文件也可能被修改过。
+// 生成计划时设为 dry run 的任务会被跳过 (参见 TaskPlan.DryRun)。
+// 注意，如果任务采用了在 YAML 里定义的 recipe (macros), 重新生成计划前需要先加载该文件。
+func (plan Plan) Apply(replan bool) error {
+	if plan.Version > PlanVersion {
+		return fmt.Errorf("plan version %d is not supported, please upgrade gof", plan.Version)
+	}
+	if plan.Version < PlanVersion {
+		return fmt.Errorf("plan version %d is outdated, please run gof plan again", plan.Version)
+	}
+	for _, tp := range plan.Tasks {
+		if tp.DryRun {
+			log.Printf("%s: skipped (dry run)", tp.Title())
+			continue
+		}
+		drift, err := tp.Drift()
+		if err != nil {
+			return err
+		}
+		if len(drift) > 0 {
+			if !replan {
+				return fmt.Errorf("%s: files have changed since the plan was made (use -replan to plan again):\n  %s",
+					tp.Title(), strings.Join(drift, "\n  "))
+			}
+			log.Printf("%s: files have changed, planning again", tp.Title())
+			if tp, err = replanTask(tp.Task, plan.Safety); err != nil {
+				return err
+			}
+		} else if err := checkPlanned(tp, plan.Safety); err != nil {
+			return err
+		}
+		if len(tp.Ops) == 0 {
+			log.Printf("%s: nothing to do", tp.Title())
+		}
+		if err := execOps(filesys.OS{}, tp.Title(), tp.Ops); err != nil {
+			return err
+		}
+	}
+	log.Print("all tasks are finished.")
+	return nil
+}
+
+// replanTask 根据 task 的当前文件重新生成计划，并按照 safety 进行安全检查。
+func replanTask(task Task, safety *Safety) (tp TaskPlan, err error) {
+	all := Tasks{onValidate: func(task Task, recipe recipes.Recipe) (err error) {
+		if tp, err = newTaskPlan(filesys.OS{}, task, recipe); err != nil {
+			return
+		}
+		if err = safety.check(filesys.OS{}, recipe, tp.Ops); err != nil {
+			return fmt.Errorf("%s: %w", task.title(), err)
+		}
+		return
+	}}
+	err = all.execTask(task, false)
+	return
+}
+
+// checkPlanned 按照 safety 检查计划里的操作 (在任务的工作目录内检查)。
+// 计划里没有 recipe, 因此无法进行 recipes.Scoper 的检查，这些检查在生成计划时已经做过了。
+func checkPlanned(tp TaskPlan, safety *Safety) error {
+	return inDir(tp.Task.Workdir, func() error {
+		if err := safety.check(filesys.OS{}, nil, tp.Ops); err != nil {
+			return fmt.Errorf("%s: %w", tp.Title(), err)
+		}
+		return nil
+	})
+}
+
+// newTaskPlan 生成一个任务的计划，必须在任务的工作目录内执行 (参见 Tasks.onValidate)。
+func newTaskPlan(fsys filesys.FS, task Task, recipe recipes.Recipe) (tp TaskPlan, err error) {
+	if tp.Ops, err = planOps(recipe); err != nil {
+		return tp, fmt.Errorf("%s: %w", task.title(), err)
+	}
+	if task.Workdir, err = os.Getwd(); err != nil {
+		return
+	}
+	task.NamesFile = ""
+	task.Foreach = nil
+	task.When = nil
+	tp.Task = task
+	tp.DryRun = isDryRunTask(task, recipe)
+
+	seen := make(map[string]bool)
+	addState := func(name string) error {
+		if seen[name] {
+			return nil
+		}
+		seen[name] = true
+		state, err := stateOf(fsys, name)
+		if err != nil {
+			return err
+		}
+		tp.Files = append(tp.Files, state)
+		return nil
+	}
+	for _, op := range tp.Ops {
+		for _, name := range op.Paths() {
+			if err = addState(name); err != nil {
+				return
+			}
+		}
+		// 删除文件夹时会删除其全部内容，因此其中的每个文件都要记录。
+		if op.Kind == recipes.OpDelete {
+			err = fsys.WalkDir(op.Dest, func(name string, _ fs.DirEntry, err error) error {
+				if errors.Is(err, fs.ErrNotExist) {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				return addState(name)
+			})
+			if err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// planOps 返回 recipe 将要执行的操作，并把路径都转换为绝对路径。
+func planOps(recipe recipes.Recipe) ([]recipes.Op, error) {
+	planner, ok := recipe.(recipes.Planner)
+	if !ok {
+		return nil, fmt.Errorf("recipe %s does not support plan", recipe.Name())
+	}
+	ops, err := planner.Plan()
+	if err != nil {
+		return nil, err
+	}
+	for i := range ops {
+		if ops[i].Src != "" {
+			if ops[i].Src, err = filepath.Abs(ops[i].Src); err != nil {
+				return nil, err
+			}
+		}
+		if ops[i].Dest, err = filepath.Abs(ops[i].Dest); err != nil {
+			return nil, err
+		}
+	}
+	return ops, nil
+}
+
+// execOps 通过 fsys 依次执行 ops, title 是任务的名称，用于显示执行进度。
+func execOps(fsys filesys.FS, title string, ops []recipes.Op) error {
+	for _, op := range ops {
+		log.Printf("%s: %s", title, op)
+		if err := op.Exec(fsys); err != nil {
+			return fmt.Errorf("%s: %w", title, err)
+		}
+	}
+	return nil
+}
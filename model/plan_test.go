@@ -0,0 +1,162 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ahui2016/gof/filesys"
+	"github.com/ahui2016/gof/recipes"
+)
+
+// planned 是实现了 recipes.Planner 的 recipe, 其操作是固定的 (只用于生成计划)。
+type planned struct {
+	recipes.Recipe
+	ops []recipes.Op
+}
+
+func (p planned) Plan() ([]recipes.Op, error) { return p.ops, nil }
+
+// writeMemFile 把 content 写入 fsys 里的文件 name, 并把修改日期设为 modTime.
+func writeMemFile(t *testing.T, fsys filesys.FS, name, content string, modTime time.Time) {
+	t.Helper()
+	f, err := fsys.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.Chtimes(name, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDrift(t *testing.T) {
+	modTime := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+	ops := []recipes.Op{
+		{Kind: recipes.OpUpdate, Src: "/src/a", Dest: "/backup/a"},
+		{Kind: recipes.OpAdd, Src: "/src/c", Dest: "/backup/c"},
+		{Kind: recipes.OpDelete, Dest: "/backup/b"},
+		{Kind: recipes.OpDelete, Dest: "/backup/old"},
+	}
+	tests := []struct {
+		name   string
+		change func(t *testing.T, fsys filesys.FS)
+		want   []string
+	}{
+		{
+			name:   "no change",
+			change: func(t *testing.T, fsys filesys.FS) {},
+		},
+		{
+			name: "unrelated file",
+			change: func(t *testing.T, fsys filesys.FS) {
+				writeMemFile(t, fsys, "/backup/other", "x", modTime)
+			},
+		},
+		{
+			name: "content changed",
+			change: func(t *testing.T, fsys filesys.FS) {
+				writeMemFile(t, fsys, "/backup/a", "AA", modTime)
+			},
+			want: []string{"/backup/a: content changed"},
+		},
+		{
+			name: "size changed",
+			change: func(t *testing.T, fsys filesys.FS) {
+				writeMemFile(t, fsys, "/src/a", "aaa", modTime)
+			},
+			want: []string{"/src/a: size changed (2 -> 3)"},
+		},
+		{
+			name: "modification time changed",
+			change: func(t *testing.T, fsys filesys.FS) {
+				writeMemFile(t, fsys, "/backup/a", "aa", modTime.Add(time.Second))
+			},
+			want: []string{"/backup/a: modification time changed"},
+		},
+		{
+			name: "deleted",
+			change: func(t *testing.T, fsys filesys.FS) {
+				if err := fsys.Remove("/backup/b"); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: []string{"/backup/b: deleted"},
+		},
+		{
+			name: "created",
+			change: func(t *testing.T, fsys filesys.FS) {
+				writeMemFile(t, fsys, "/backup/c", "c", modTime)
+			},
+			want: []string{"/backup/c: created"},
+		},
+		{
+			name: "file added to a folder to be deleted",
+			change: func(t *testing.T, fsys filesys.FS) {
+				writeMemFile(t, fsys, "/backup/old/new", "new", modTime)
+			},
+			want: []string{"/backup/old: folder contents changed"},
+		},
+		{
+			name: "file added to a subfolder of a folder to be deleted",
+			change: func(t *testing.T, fsys filesys.FS) {
+				writeMemFile(t, fsys, "/backup/old/sub/new", "new", modTime)
+			},
+			want: []string{"/backup/old/sub: folder contents changed"},
+		},
+		{
+			name: "file changed in a folder to be deleted",
+			change: func(t *testing.T, fsys filesys.FS) {
+				writeMemFile(t, fsys, "/backup/old/sub/2", "changed", modTime)
+			},
+			want: []string{"/backup/old/sub/2: size changed (2 -> 7)"},
+		},
+		{
+			name: "folder replaced by a file",
+			change: func(t *testing.T, fsys filesys.FS) {
+				if err := fsys.RemoveAll("/backup/old"); err != nil {
+					t.Fatal(err)
+				}
+				writeMemFile(t, fsys, "/backup/old", "old", modTime)
+			},
+			want: []string{
+				"/backup/old: file type changed",
+				"/backup/old/1: deleted",
+				"/backup/old/sub: deleted",
+				"/backup/old/sub/2: deleted",
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := filesys.NewMem()
+			for _, dir := range []string{"/src", "/backup/old/sub"} {
+				if err := filesys.MkdirAll(fsys, dir); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for name, content := range map[string]string{
+				"/src/a": "aa", "/src/c": "c", "/backup/a": "aa", "/backup/b": "b",
+				"/backup/old/1": "1", "/backup/old/sub/2": "22",
+			} {
+				writeMemFile(t, fsys, name, content, modTime)
+			}
+
+			tp, err := newTaskPlan(fsys, Task{Recipe: "sync"}, planned{ops: ops})
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.change(t, fsys)
+			drift, err := tp.drift(fsys)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(drift, tt.want) {
+				t.Errorf("got %q, want %q", drift, tt.want)
+			}
+		})
+	}
+}
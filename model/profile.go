@@ -0,0 +1,105 @@
+package model
+
+import (
+	"fmt"
+
+	"github.com/ahui2016/gof/recipes"
+)
+
+// Profile 是同一个 YAML 文件里的一套设定，用 -profile 选择，用于覆盖文件里的设定，例如：
+//
+//	profiles:
+//	  office:
+//	    vars:
+//	      usb: E:/
+//	    tasks:
+//	      backup-photos:        # 任务名称 (Task.Name)
+//	        enabled: false
+//	      sync-docs:
+//	        options:
+//	          delete: "yes"
+//	        names: [E:/docs/, ./docs/]
+type Profile struct {
+	Names   []string                `yaml:"global-names,omitempty" json:"global-names,omitempty" toml:"global-names,omitempty"` // 覆盖 global-names
+	Workdir string                  `yaml:"workdir,omitempty" json:"workdir,omitempty" toml:"workdir,omitempty"`                // 覆盖顶层的 workdir
+	Vars    map[string]string       `yaml:"vars,omitempty" json:"vars,omitempty" toml:"vars,omitempty"`                         // 覆盖 vars
+	Tasks   map[string]TaskOverride `yaml:"tasks,omitempty" json:"tasks,omitempty" toml:"tasks,omitempty"`                      // 按任务名称覆盖任务里的设定
+}
+
+// TaskOverride 用于覆盖一个任务里的设定，其中 options 是逐项覆盖，其它项目则是整体覆盖。
+type TaskOverride struct {
+	Enabled *bool           `yaml:"enabled,omitempty" json:"enabled,omitempty" toml:"enabled,omitempty"`
+	Options recipes.Options `yaml:"options,omitempty" json:"options,omitempty" toml:"options,omitempty"`
+	Names   []string        `yaml:"names,omitempty" json:"names,omitempty" toml:"names,omitempty"`
+	Workdir string          `yaml:"workdir,omitempty" json:"workdir,omitempty" toml:"workdir,omitempty"`
+}
+
+// useProfile 采用名为 name 的 profile, 覆盖 global-names, workdir 与 vars,
+// 返回的 profile 用于覆盖每个任务里的设定。
+func (all *Tasks) useProfile(name string) (*Profile, error) {
+	p, ok := all.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("not found profile: %s", name)
+	}
+	if len(p.Names) > 0 {
+		all.Names = p.Names
+	}
+	if p.Workdir != "" {
+		all.Workdir = p.Workdir
+	}
+	for k, v := range p.Vars {
+		if all.Vars == nil {
+			all.Vars = make(map[string]string)
+		}
+		all.Vars[k] = v
+	}
+	return &p, nil
+}
+
+// override 用 profile 里的设定覆盖同名任务的设定。
+func (p *Profile) override(tasks []Task) {
+	for i := range tasks {
+		task := &tasks[i]
+		o, ok := p.Tasks[task.Name]
+		if task.Name == "" || !ok {
+			continue
+		}
+		if o.Enabled != nil {
+			task.Enabled = o.Enabled
+		}
+		if len(o.Names) > 0 {
+			task.Names = o.Names
+			task.NamesFile = ""
+		}
+		if o.Workdir != "" {
+			task.Workdir = o.Workdir
+		}
+		if len(o.Options) > 0 {
+			options := make(recipes.Options)
+			for k, v := range task.Options {
+				options[k] = v
+			}
+			for k, v := range o.Options {
+				options[k] = v
+			}
+			task.Options = options
+		}
+	}
+}
+
+// check 检查 profile 里的每个任务名称是否都存在，以免因拼写错误而导致设定无效。
+func (p *Profile) check(tasks []Task) error {
+	for name := range p.Tasks {
+		found := false
+		for _, task := range tasks {
+			if task.Name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("not found task: %s", name)
+		}
+	}
+	return nil
+}
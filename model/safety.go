@@ -0,0 +1,251 @@
+package model
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ahui2016/gof/filesys"
+	"github.com/ahui2016/gof/recipes"
+	"github.com/ahui2016/gof/util"
+)
+
+// Safety 是针对删除等危险操作的安全设定，例如：
+//
+//	safety:
+//	  protected: [/home/me/Documents, ./src]
+//	  allowed-roots: [/mnt/backup]
+//	  max-delete: 100
+//	  max-delete-percent: 20
+//
+// 相对路径是相对于任务文件所在的文件夹。被导入的文件里的 safety 会与当前文件的合并。
+//
+// 无论是否有设定，以下检查总是有效（只针对实现了 recipes.Planner 的 recipe）：
+// 不可修改或删除根目录与 home 文件夹本身，也不可删除其上层文件夹；
+// 如果 recipe 实现了 recipes.Scoper, 则删除操作只能在目标文件夹内进行，目标文件夹不可以是根目录或 home 文件夹，
+// 并且源头文件不存在或源头文件夹是空的时候（比如忘了挂载硬盘）拒绝删除。
+type Safety struct {
+	// 受保护的文件或文件夹（包括其内容），不可修改或删除。
+	Protected []string `yaml:"protected,omitempty" json:"protected,omitempty" toml:"protected,omitempty"`
+
+	// 如果有设定，则只能修改或删除这些文件夹里的文件。
+	AllowedRoots []string `yaml:"allowed-roots,omitempty" json:"allowed-roots,omitempty" toml:"allowed-roots,omitempty"`
+
+	// 一个任务最多可删除多少个文件（文件夹里的每个文件都算在内），超过则中止该任务，0 表示不限。
+	MaxDelete int `yaml:"max-delete,omitempty" json:"max-delete,omitempty" toml:"max-delete,omitempty"`
+
+	// 一个任务最多可删除目标文件夹里百分之多少的文件，超过则中止该任务，0 表示不限。
+	// 只对实现了 recipes.Scoper 的 recipe 有效。
+	MaxDeletePercent float64 `yaml:"max-delete-percent,omitempty" json:"max-delete-percent,omitempty" toml:"max-delete-percent,omitempty"`
+}
+
+// resolve 替换变量，并把相对路径转换为绝对路径（相对于 dir）。
+func (s *Safety) resolve(dir string, vars map[string]string) {
+	if s == nil {
+		return
+	}
+	for _, list := range [][]string{s.Protected, s.AllowedRoots} {
+		for i := range list {
+			list[i] = absPath(dir, util.ExpandVars(list[i], vars))
+		}
+	}
+}
+
+// merge 把被导入的文件里的设定合并到 s 里，限制数量取较严格（较小）的一个。
+func (s *Safety) merge(sub *Safety) *Safety {
+	if sub == nil {
+		return s
+	}
+	if s == nil {
+		s = new(Safety)
+	}
+	s.Protected = append(s.Protected, sub.Protected...)
+	s.AllowedRoots = append(s.AllowedRoots, sub.AllowedRoots...)
+	if sub.MaxDelete > 0 && (s.MaxDelete == 0 || sub.MaxDelete < s.MaxDelete) {
+		s.MaxDelete = sub.MaxDelete
+	}
+	if sub.MaxDeletePercent > 0 && (s.MaxDeletePercent == 0 || sub.MaxDeletePercent < s.MaxDeletePercent) {
+		s.MaxDeletePercent = sub.MaxDeletePercent
+	}
+	return s
+}
+
+// check 检查 recipe 将要执行的操作是否违反安全设定，必须在任务的工作目录内执行。
+// ops 的路径都必须是绝对路径 (参见 planOps)。s 为 nil 时只进行总是有效的检查。
+// recipe 为 nil 时（比如执行计划文件时）不进行 recipes.Scoper 的检查。
+// fsys 用于读取源头文件与统计文件数量，应与 recipe 采用的文件系统相同。
+func (s *Safety) check(fsys filesys.FS, recipe recipes.Recipe, ops []recipes.Op) error {
+	if s == nil {
+		s = new(Safety)
+	}
+	builtin := builtinProtected()
+	var deletes []string
+	for _, op := range ops {
+		for _, name := range modifiedPaths(op) {
+			for _, p := range builtin {
+				if name == p {
+					return fmt.Errorf("%s: refuse to modify %s", op, p)
+				}
+			}
+			for _, p := range s.Protected {
+				if isInside(name, p) {
+					return fmt.Errorf("%s: %s is protected", op, p)
+				}
+			}
+			if op.Kind == recipes.OpDelete {
+				for _, p := range append(builtin, s.Protected...) {
+					if isInside(p, name) {
+						return fmt.Errorf("%s: refuse to delete %s", op, p)
+					}
+				}
+			}
+			if len(s.AllowedRoots) > 0 && !isInsideAny(name, s.AllowedRoots) {
+				return fmt.Errorf("%s: %s is not in the allowed roots", op, name)
+			}
+		}
+		if op.Kind == recipes.OpDelete {
+			deletes = append(deletes, op.Dest)
+		}
+	}
+	if len(deletes) == 0 {
+		return nil
+	}
+
+	target := ""
+	if scoper, ok := recipe.(recipes.Scoper); ok {
+		var err error
+		if target, err = filepath.Abs(scoper.Target()); err != nil {
+			return err
+		}
+		if err := checkScope(fsys, target, scoper.Sources(), deletes, builtin); err != nil {
+			return err
+		}
+	}
+	return s.checkCount(fsys, target, deletes)
+}
+
+// checkScope 检查删除操作是否都在目标文件夹内，以及源头文件是否存在且不为空。
+func checkScope(fsys filesys.FS, target string, sources, deletes, builtin []string) error {
+	for _, p := range builtin {
+		if target == p {
+			return fmt.Errorf("refuse to delete files in %s", target)
+		}
+	}
+	for _, name := range deletes {
+		if name == target || !isInside(name, target) {
+			return fmt.Errorf("delete %s: it is not in the target folder %s", name, target)
+		}
+	}
+	for _, src := range sources {
+		if err := filesys.FindFile(fsys, src); err != nil {
+			return fmt.Errorf("refuse to delete files: %w", err)
+		}
+		entries, err := fsys.ReadDir(src)
+		if err == nil && len(entries) == 0 {
+			return fmt.Errorf("refuse to delete files: the source folder %s is empty (is the drive mounted?)", src)
+		}
+	}
+	return nil
+}
+
+// checkCount 检查删除的文件数量是否超过 MaxDelete 与 MaxDeletePercent.
+func (s *Safety) checkCount(fsys filesys.FS, target string, deletes []string) error {
+	if s.MaxDelete == 0 && s.MaxDeletePercent == 0 {
+		return nil
+	}
+	n, _, err := countFiles(fsys, deletes, true)
+	if err != nil {
+		return err
+	}
+	if s.MaxDelete > 0 && n > s.MaxDelete {
+		return fmt.Errorf("refuse to delete %d files, more than max-delete (%d)", n, s.MaxDelete)
+	}
+	if s.MaxDeletePercent == 0 || target == "" {
+		return nil
+	}
+	total, _, err := countFiles(fsys, []string{target}, false)
+	if err != nil {
+		return err
+	}
+	if total > 0 && float64(n)*100/float64(total) > s.MaxDeletePercent {
+		return fmt.Errorf("refuse to delete %d of %d files in %s, more than max-delete-percent (%g%%)",
+			n, total, target, s.MaxDeletePercent)
+	}
+	return nil
+}
+
+// CountDeletes 统计 ops 将要删除的文件数量（包括被删除的文件夹里的文件）及其总大小，
+// 数量的统计方法与安全检查的 max-delete 相同。
+func CountDeletes(fsys filesys.FS, ops []recipes.Op) (n int, size int64, err error) {
+	var deletes []string
+	for _, op := range ops {
+		if op.Kind == recipes.OpDelete {
+			deletes = append(deletes, op.Dest)
+		}
+	}
+	return countFiles(fsys, deletes, true)
+}
+
+// countFiles 统计 names 里的文件以及文件夹里的文件的数量与总大小，
+// self 为 false 时不包括 names 本身。
+func countFiles(fsys filesys.FS, names []string, self bool) (n int, size int64, err error) {
+	for _, name := range names {
+		err = fsys.WalkDir(name, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !self && path == name {
+				return nil
+			}
+			n++
+			if d.Type().IsRegular() {
+				info, err := d.Info()
+				if err != nil {
+					return err
+				}
+				size += info.Size()
+			}
+			return nil
+		})
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// modifiedPaths 返回 op 会修改或删除的文件路径。
+func modifiedPaths(op recipes.Op) []string {
+	if op.Kind == recipes.OpMove || op.Kind == recipes.OpRename {
+		return []string{op.Src, op.Dest}
+	}
+	return []string{op.Dest}
+}
+
+// builtinProtected 返回总是受保护的文件夹：根目录与 home 文件夹。
+func builtinProtected() (dirs []string) {
+	if cwd, err := os.Getwd(); err == nil {
+		dirs = append(dirs, filepath.VolumeName(cwd)+string(filepath.Separator))
+	}
+	if home, err := os.UserHomeDir(); err == nil && home != "" {
+		dirs = append(dirs, filepath.Clean(home))
+	}
+	return
+}
+
+// isInside 在 name 等于 dir 或位于 dir 之内时返回 true (name 与 dir 都必须是绝对路径)。
+func isInside(name, dir string) bool {
+	rel, err := filepath.Rel(dir, name)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func isInsideAny(name string, dirs []string) bool {
+	for _, dir := range dirs {
+		if isInside(name, dir) {
+			return true
+		}
+	}
+	return false
+}
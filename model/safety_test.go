@@ -0,0 +1,210 @@
+package model
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ahui2016/gof/filesys"
+	"github.com/ahui2016/gof/recipes"
+)
+
+// newMemFS 新建一个 filesys.Mem, tree 的 key 是绝对路径，值为 "/" 表示文件夹，否则是文件内容。
+func newMemFS(t *testing.T, tree map[string]string) *filesys.Mem {
+	t.Helper()
+	m := filesys.NewMem()
+	for name, content := range tree {
+		if err := filesys.MkdirAll(m, filepath.Dir(name)); err != nil {
+			t.Fatal(err)
+		}
+		if content == "/" {
+			if err := filesys.MkdirAll(m, name); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		f, err := m.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return m
+}
+
+// safetyTree 是安全检查的测试用的文件：目标文件夹 /backup 里有 10 个文件（包括文件夹），源头文件夹是 /src.
+var safetyTree = map[string]string{
+	"/backup/a":         "aa",
+	"/backup/b":         "bbb",
+	"/backup/old":       "/",
+	"/backup/old/1":     "1",
+	"/backup/old/2":     "22",
+	"/backup/old/3":     "333",
+	"/backup/old/sub":   "/",
+	"/backup/old/sub/4": "4444",
+	"/backup/x":         "/",
+	"/backup/y":         "",
+	"/src/a":            "a",
+	"/empty":            "/",
+}
+
+func TestCountFiles(t *testing.T) {
+	fsys := newMemFS(t, safetyTree)
+	tests := []struct {
+		names    []string
+		self     bool
+		wantN    int
+		wantSize int64
+	}{
+		{[]string{"/backup/a"}, true, 1, 2},
+		{[]string{"/backup/old"}, true, 6, 10},
+		{[]string{"/backup/old"}, false, 5, 10},
+		{[]string{"/backup/a", "/backup/old/sub"}, true, 3, 6},
+		{[]string{"/backup"}, false, 10, 15},
+		{[]string{"/empty"}, true, 1, 0},
+		{nil, true, 0, 0},
+	}
+	for _, tt := range tests {
+		n, size, err := countFiles(fsys, tt.names, tt.self)
+		if err != nil {
+			t.Fatalf("%v: %v", tt.names, err)
+		}
+		if n != tt.wantN || size != tt.wantSize {
+			t.Errorf("countFiles(%v, %v) = %d, %d, want %d, %d", tt.names, tt.self, n, size, tt.wantN, tt.wantSize)
+		}
+	}
+
+	if _, _, err := countFiles(fsys, []string{"/missing"}, true); err == nil {
+		t.Error("countFiles of a missing file: want an error")
+	}
+}
+
+func TestCheckCount(t *testing.T) {
+	fsys := newMemFS(t, safetyTree)
+	tests := []struct {
+		name    string
+		safety  Safety
+		target  string
+		deletes []string
+		wantErr string // 错误信息应包含的内容，空字符串表示不应出错
+	}{
+		{"no limit", Safety{}, "/backup", []string{"/backup/old"}, ""},
+		{"under max-delete", Safety{MaxDelete: 6}, "", []string{"/backup/old"}, ""},
+		{"over max-delete", Safety{MaxDelete: 5}, "", []string{"/backup/old"}, "more than max-delete (5)"},
+		{"files in folders count", Safety{MaxDelete: 2}, "", []string{"/backup/a", "/backup/old/sub"}, "refuse to delete 3 files"},
+		{"under max-delete-percent", Safety{MaxDeletePercent: 60}, "/backup", []string{"/backup/old"}, ""},
+		{"over max-delete-percent", Safety{MaxDeletePercent: 50}, "/backup", []string{"/backup/old"}, "refuse to delete 6 of 10 files"},
+		{"percent without target", Safety{MaxDeletePercent: 1}, "", []string{"/backup/old"}, ""},
+		{"empty target", Safety{MaxDeletePercent: 1}, "/empty", []string{"/backup/a"}, ""},
+	}
+	for _, tt := range tests {
+		err := tt.safety.checkCount(fsys, tt.target, tt.deletes)
+		checkErr(t, tt.name, err, tt.wantErr)
+	}
+}
+
+func TestCountDeletes(t *testing.T) {
+	fsys := newMemFS(t, safetyTree)
+	ops := []recipes.Op{
+		{Kind: recipes.OpAdd, Src: "/src/a", Dest: "/backup/c", Size: 1},
+		{Kind: recipes.OpDelete, Dest: "/backup/b", Size: 3},
+		{Kind: recipes.OpDelete, Dest: "/backup/old"},
+	}
+	n, size, err := CountDeletes(fsys, ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 7 || size != 13 {
+		t.Errorf("CountDeletes = %d, %d, want 7, 13", n, size)
+	}
+}
+
+// scoped 是实现了 recipes.Scoper 的 recipe (只用于安全检查)。
+type scoped struct {
+	recipes.Recipe
+	target  string
+	sources []string
+}
+
+func (s scoped) Target() string    { return s.target }
+func (s scoped) Sources() []string { return s.sources }
+
+func TestSafetyCheck(t *testing.T) {
+	fsys := newMemFS(t, safetyTree)
+	del := func(name string) recipes.Op { return recipes.Op{Kind: recipes.OpDelete, Dest: name} }
+	add := func(name string) recipes.Op { return recipes.Op{Kind: recipes.OpAdd, Src: "/src/a", Dest: name} }
+	sync := scoped{target: "/backup", sources: []string{"/src"}}
+
+	tests := []struct {
+		name    string
+		safety  *Safety
+		recipe  recipes.Recipe
+		ops     []recipes.Op
+		wantErr string
+	}{
+		{"nil safety", nil, nil, []recipes.Op{add("/backup/c"), del("/backup/a")}, ""},
+		{"root", nil, nil, []recipes.Op{del("/")}, "refuse to modify /"},
+		{"parent of protected", &Safety{Protected: []string{"/backup/old/sub"}}, nil,
+			[]recipes.Op{del("/backup/old")}, "refuse to delete /backup/old/sub"},
+		{"inside protected", &Safety{Protected: []string{"/backup/old"}}, nil,
+			[]recipes.Op{add("/backup/old/new")}, "/backup/old is protected"},
+		{"protected itself", &Safety{Protected: []string{"/backup/a"}}, nil,
+			[]recipes.Op{del("/backup/a")}, "/backup/a is protected"},
+		{"beside protected", &Safety{Protected: []string{"/backup/old"}}, nil,
+			[]recipes.Op{del("/backup/older"), add("/backup/c")}, ""},
+		{"inside allowed roots", &Safety{AllowedRoots: []string{"/empty", "/backup"}}, nil,
+			[]recipes.Op{add("/backup/c"), del("/backup/old")}, ""},
+		{"outside allowed roots", &Safety{AllowedRoots: []string{"/backup"}}, nil,
+			[]recipes.Op{add("/backup/c"), add("/other/c")}, "/other/c is not in the allowed roots"},
+		{"moves check both paths", &Safety{AllowedRoots: []string{"/backup"}}, nil,
+			[]recipes.Op{{Kind: recipes.OpMove, Src: "/src/a", Dest: "/backup/a2"}}, "/src/a is not in the allowed roots"},
+		{"delete in target", nil, sync, []recipes.Op{del("/backup/old")}, ""},
+		{"delete outside target", nil, sync, []recipes.Op{del("/src/a")}, "not in the target folder"},
+		{"delete the target", nil, sync, []recipes.Op{del("/backup")}, "not in the target folder"},
+		{"empty source", nil, scoped{target: "/backup", sources: []string{"/empty"}},
+			[]recipes.Op{del("/backup/a")}, "the source folder /empty is empty"},
+		{"missing source", nil, scoped{target: "/backup", sources: []string{"/missing"}},
+			[]recipes.Op{del("/backup/a")}, "refuse to delete files"},
+		{"empty source without deletes", nil, scoped{target: "/backup", sources: []string{"/empty"}},
+			[]recipes.Op{add("/backup/c")}, ""},
+		{"max-delete-percent with target", &Safety{MaxDeletePercent: 10}, sync,
+			[]recipes.Op{del("/backup/a"), del("/backup/b")}, "refuse to delete 2 of 10 files"},
+	}
+	for _, tt := range tests {
+		err := tt.safety.check(fsys, tt.recipe, tt.ops)
+		checkErr(t, tt.name, err, tt.wantErr)
+	}
+}
+
+func TestIsInside(t *testing.T) {
+	tests := []struct {
+		name, dir string
+		want      bool
+	}{
+		{"/a", "/a", true},
+		{"/a/b", "/a", true},
+		{"/ab", "/a", false},
+		{"/", "/a", false},
+		{"/a", "/", true},
+	}
+	for _, tt := range tests {
+		if got := isInside(tt.name, tt.dir); got != tt.want {
+			t.Errorf("isInside(%q, %q) = %v, want %v", tt.name, tt.dir, got, tt.want)
+		}
+	}
+}
+
+// checkErr 检查 err 是否包含 want, want 为空字符串表示不应出错。
+func checkErr(t *testing.T, name string, err error, want string) {
+	t.Helper()
+	switch {
+	case want == "" && err != nil:
+		t.Errorf("%s: unexpected error: %v", name, err)
+	case want != "" && err == nil:
+		t.Errorf("%s: want an error containing %q", name, want)
+	case want != "" && !strings.Contains(err.Error(), want):
+		t.Errorf("%s: got %q, want an error containing %q", name, err, want)
+	}
+}
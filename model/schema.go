@@ -0,0 +1,180 @@
+package model
+
+import (
+	"github.com/ahui2016/gof/recipes"
+)
+
+// object 是 JSON Schema 里的一个对象。
+type object = map[string]interface{}
+
+// Schema 返回任务文件的 JSON Schema, 包括每个已注册的 recipe 的名称及其 options,
+// 可供编辑器（比如 YAML language server）用于自动补全与检查。
+func Schema() object {
+	stringList := object{"type": "array", "items": object{"type": "string"}}
+	stringMap := object{"type": "object", "additionalProperties": object{"type": "string"}}
+
+	// 别名（包括已废弃的名称）也可以用作 recipe 的名称。
+	var recipeNames []string
+	var conditions []interface{}
+	defaults := object{}
+	for _, name := range recipes.Names() {
+		options := optionsSchema(recipes.Get[name])
+		aliases, deprecated := recipes.AliasesOf(name)
+		for _, alias := range append(append([]string{name}, aliases...), deprecated...) {
+			recipeNames = append(recipeNames, alias)
+			defaults[alias] = options
+			conditions = append(conditions, object{
+				"if":   object{"properties": object{"recipe": object{"const": alias}}},
+				"then": object{"properties": object{"options": options}},
+			})
+		}
+	}
+
+	when := object{
+		"type": "object",
+		"properties": object{
+			"exists":     object{"type": "string", "description": "文件或文件夹存在"},
+			"not-exists": object{"type": "string", "description": "文件或文件夹不存在"},
+			"not-empty":  object{"type": "string", "description": "文件夹不是空的"},
+			"free-space": object{
+				"type":        "object",
+				"description": "剩余空间不少于 min",
+				"properties": object{
+					"path": object{"type": "string"},
+					"min":  object{"type": "string", "examples": []string{"500MB", "10GB"}},
+				},
+				"required": []string{"path", "min"},
+			},
+			"hostname": object{"type": "string", "description": "主机名（不区分大小写）"},
+			"os":       object{"type": "string", "description": "操作系统，与 Go 语言的 GOOS 相同"},
+			"env":      object{"type": "string", "description": "环境变量不为空，也可以写成 NAME=value"},
+			"changed":  object{"type": "boolean", "description": "上一个任务修改了文件"},
+		},
+		"additionalProperties": false,
+	}
+
+	task := object{
+		"type": "object",
+		"properties": object{
+			"name":           object{"type": "string", "description": "任务名称，用于在 profile 里指定任务"},
+			"enabled":        object{"type": "boolean", "default": true},
+			"recipe":         object{"type": "string", "enum": recipeNames},
+			"options":        object{"type": "object"},
+			"names":          stringList,
+			"names-file":     object{"type": "string", "description": "从文件中读取 names, - 表示 stdin"},
+			"workdir":        object{"type": "string", "description": "执行该任务时的工作目录"},
+			"portable-paths": object{"type": "boolean"},
+			"foreach": object{"oneOf": []interface{}{
+				object{"type": "string", "description": "glob"},
+				object{"type": "array", "items": stringMap},
+			}},
+			"when": when,
+		},
+		"required":             []string{"recipe"},
+		"additionalProperties": false,
+		"allOf":                conditions,
+	}
+
+	// macro 里的任务的 options 可以引用参数（比如 ${dry-run}）, 因此不检查 options.
+	macroTask := object{}
+	for k, v := range task {
+		if k != "allOf" {
+			macroTask[k] = v
+		}
+	}
+
+	taskOverride := object{
+		"type": "object",
+		"properties": object{
+			"enabled": object{"type": "boolean"},
+			"options": object{"type": "object"},
+			"names":   stringList,
+			"workdir": object{"type": "string"},
+		},
+		"additionalProperties": false,
+	}
+
+	return object{
+		"$schema":     "http://json-schema.org/draft-07/schema#",
+		"title":       "gof task file",
+		"description": "gof 的任务文件 (YAML, JSON 或 TOML)",
+		"type":        "object",
+		"properties": object{
+			"version":           object{"type": "integer", "minimum": 1, "maximum": SchemaVersion},
+			"global-names":      stringList,
+			"all-tasks":         object{"type": "array", "items": task},
+			"workdir":           object{"type": "string"},
+			"paths-relative-to": object{"type": "string", "enum": []string{RelativeToCwd, RelativeToYAML}},
+			"portable-paths":    object{"type": "boolean"},
+			"safety": object{
+				"type": "object",
+				"properties": object{
+					"protected":          object{"type": "array", "items": object{"type": "string"}, "description": "受保护的文件或文件夹（包括其内容）"},
+					"allowed-roots":      object{"type": "array", "items": object{"type": "string"}, "description": "只能修改或删除这些文件夹里的文件"},
+					"max-delete":         object{"type": "integer", "minimum": 0, "description": "一个任务最多可删除多少个文件"},
+					"max-delete-percent": object{"type": "number", "minimum": 0, "maximum": 100, "description": "一个任务最多可删除目标文件夹里百分之多少的文件"},
+				},
+				"additionalProperties": false,
+			},
+			"vars": stringMap,
+			"include": object{"type": "array", "items": object{
+				"type": "object",
+				"properties": object{
+					"file": object{"type": "string"},
+					"vars": stringMap,
+				},
+				"required":             []string{"file"},
+				"additionalProperties": false,
+			}},
+			"defaults": object{
+				"type":       "object",
+				"properties": defaults,
+			},
+			"profiles": object{"type": "object", "additionalProperties": object{
+				"type": "object",
+				"properties": object{
+					"global-names": stringList,
+					"workdir":      object{"type": "string"},
+					"vars":         stringMap,
+					"tasks":        object{"type": "object", "additionalProperties": taskOverride},
+				},
+				"additionalProperties": false,
+			}},
+			"macros": object{"type": "array", "items": object{
+				"type": "object",
+				"properties": object{
+					"define": object{"type": "string"},
+					"help":   object{"type": "string"},
+					"params": stringMap,
+					"tasks":  object{"type": "array", "items": macroTask},
+				},
+				"required":             []string{"define", "tasks"},
+				"additionalProperties": false,
+			}},
+		},
+		"additionalProperties": false,
+	}
+}
+
+// optionsSchema 返回 recipe 的 options 的 JSON Schema.
+func optionsSchema(recipe recipes.Recipe) object {
+	properties := object{}
+	for _, info := range recipes.Describe(recipe) {
+		property := object{
+			"type":    []string{"string", "number", "boolean"},
+			"default": info.Default,
+		}
+		if info.Desc != "" {
+			property["description"] = info.Desc
+		}
+		if len(info.Enum) > 0 {
+			property["enum"] = info.Enum
+		}
+		properties[info.Key] = property
+	}
+	return object{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
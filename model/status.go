@@ -0,0 +1,77 @@
+package model
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/ahui2016/gof/filesys"
+	"github.com/ahui2016/gof/recipes"
+)
+
+// TaskStatus 统计一个任务将要执行的操作 (gof status)。
+type TaskStatus struct {
+	Task    string
+	Recipe  string
+	Skipped string // 跳过的原因，空字符串表示不跳过
+	Planned bool   // recipe 实现了 recipes.Planner 时为 true, 否则无法统计
+	Adds    int    // 包括新建文件夹
+	Updates int
+	Deletes int   // 将要删除的文件数量，包括被删除的文件夹里的文件 (参见 CountDeletes)
+	Moves   int   // 包括改名
+	Bytes   int64 // 涉及的文件的总大小（包括被删除的文件夹里的文件）
+}
+
+// Status 检查全部任务并统计每个任务将要执行的操作，不会修改任何文件。
+// 被停用的任务也会列出（注明跳过的原因），而不满足 when 条件的任务则不会列出。
+func (all Tasks) Status() (status []TaskStatus, err error) {
+	if len(all.AllTasks) == 0 {
+		return nil, fmt.Errorf("no task")
+	}
+	all.onValidate = func(task Task, recipe recipes.Recipe) error {
+		s := TaskStatus{Task: task.title(), Recipe: recipe.Name()}
+		if _, ok := recipe.(recipes.Planner); ok {
+			ops, err := planOps(recipe)
+			if err != nil {
+				return fmt.Errorf("%s: %w", task.title(), err)
+			}
+			if err := s.count(all.fs(), ops); err != nil {
+				return fmt.Errorf("%s: %w", task.title(), err)
+			}
+		}
+		status = append(status, s)
+		return nil
+	}
+	for _, task := range all.AllTasks {
+		if !task.isEnabled() {
+			status = append(status, TaskStatus{Task: task.title(), Recipe: task.Recipe, Skipped: "disabled"})
+			continue
+		}
+		if err = all.execTask(task, false); err != nil {
+			return
+		}
+	}
+	log.Print("all tasks are validated.")
+	return
+}
+
+// count 统计 ops 里每种操作的数量。删除的数量与大小由 fsys 里的文件统计 (参见 CountDeletes)。
+func (s *TaskStatus) count(fsys filesys.FS, ops []recipes.Op) (err error) {
+	s.Planned = true
+	for _, op := range ops {
+		switch op.Kind {
+		case recipes.OpMkdir, recipes.OpAdd:
+			s.Adds++
+		case recipes.OpUpdate:
+			s.Updates++
+		case recipes.OpDelete:
+			continue
+		case recipes.OpMove, recipes.OpRename:
+			s.Moves++
+		}
+		s.Bytes += op.Size
+	}
+	deletes, size, err := CountDeletes(fsys, ops)
+	s.Deletes = deletes
+	s.Bytes += size
+	return err
+}
@@ -0,0 +1,136 @@
+package model
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/ahui2016/gof/util"
+)
+
+// When 是任务的执行条件，只有全部条件都满足时才执行任务，否则跳过该任务。
+// 条件里的相对路径是相对于任务的工作目录。例如：
+//
+//	when:
+//	  exists: /mnt/usb/backup      # 文件或文件夹存在
+//	  not-exists: ./lock           # 文件或文件夹不存在
+//	  not-empty: ./inbox/          # 文件夹不是空的
+//	  free-space:                  # 剩余空间不少于 min
+//	    path: /mnt/usb
+//	    min: 10GB
+//	  hostname: my-laptop          # 主机名（不区分大小写）
+//	  os: linux                    # 操作系统，与 Go 语言的 GOOS 相同
+//	  env: BACKUP_DISK             # 环境变量不为空，也可以写成 NAME=value
+//	  changed: true                # 上一个任务修改了文件
+type When struct {
+	Exists    string     `yaml:"exists,omitempty" json:"exists,omitempty" toml:"exists,omitempty"`
+	NotExists string     `yaml:"not-exists,omitempty" json:"not-exists,omitempty" toml:"not-exists,omitempty"`
+	NotEmpty  string     `yaml:"not-empty,omitempty" json:"not-empty,omitempty" toml:"not-empty,omitempty"`
+	FreeSpace *FreeSpace `yaml:"free-space,omitempty" json:"free-space,omitempty" toml:"free-space,omitempty"`
+	Hostname  string     `yaml:"hostname,omitempty" json:"hostname,omitempty" toml:"hostname,omitempty"`
+	OS        string     `yaml:"os,omitempty" json:"os,omitempty" toml:"os,omitempty"`
+	Env       string     `yaml:"env,omitempty" json:"env,omitempty" toml:"env,omitempty"`
+	Changed   bool       `yaml:"changed,omitempty" json:"changed,omitempty" toml:"changed,omitempty"`
+}
+
+// FreeSpace 要求文件夹 Path 所在的硬盘分区的剩余空间不少于 Min (比如 500MB, 10GB).
+type FreeSpace struct {
+	Path string `yaml:"path" json:"path" toml:"path"`
+	Min  string `yaml:"min" json:"min" toml:"min"`
+}
+
+// String 返回条件的简短描述，用于显示被跳过的任务。
+func (w *When) String() string {
+	var conds []string
+	add := func(key, value string) {
+		if value != "" {
+			conds = append(conds, key+"="+value)
+		}
+	}
+	add("exists", w.Exists)
+	add("not-exists", w.NotExists)
+	add("not-empty", w.NotEmpty)
+	if w.FreeSpace != nil {
+		add("free-space", w.FreeSpace.Path+">="+w.FreeSpace.Min)
+	}
+	add("hostname", w.Hostname)
+	add("os", w.OS)
+	add("env", w.Env)
+	if w.Changed {
+		add("changed", "true")
+	}
+	return strings.Join(conds, ", ")
+}
+
+// expand 返回一个替换了变量的新 When (参见 util.ExpandVars)。
+func (w *When) expand(vars map[string]string) *When {
+	expanded := *w
+	expanded.Exists = util.ExpandVars(w.Exists, vars)
+	expanded.NotExists = util.ExpandVars(w.NotExists, vars)
+	expanded.NotEmpty = util.ExpandVars(w.NotEmpty, vars)
+	if w.FreeSpace != nil {
+		expanded.FreeSpace = &FreeSpace{
+			Path: util.ExpandVars(w.FreeSpace.Path, vars),
+			Min:  util.ExpandVars(w.FreeSpace.Min, vars),
+		}
+	}
+	return &expanded
+}
+
+// Check 检查全部条件是否都满足，dir 是任务的工作目录，changed 表示上一个任务是否修改了文件。
+func (w *When) Check(dir string, changed bool) (bool, error) {
+	if w.Exists != "" {
+		ok, err := util.PathIsExist(absPath(dir, w.Exists))
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	if w.NotExists != "" {
+		ok, err := util.PathIsNotExist(absPath(dir, w.NotExists))
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	if w.NotEmpty != "" {
+		entries, err := os.ReadDir(absPath(dir, w.NotEmpty))
+		if err != nil && !os.IsNotExist(err) {
+			return false, err
+		}
+		if len(entries) == 0 {
+			return false, nil
+		}
+	}
+	if w.FreeSpace != nil {
+		min, err := util.ParseSize(w.FreeSpace.Min)
+		if err != nil {
+			return false, fmt.Errorf("when: free-space: %w", err)
+		}
+		free, err := util.FreeSpace(absPath(dir, w.FreeSpace.Path))
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		if err != nil || free < min {
+			return false, err
+		}
+	}
+	if w.Hostname != "" {
+		hostname, err := os.Hostname()
+		if err != nil || !strings.EqualFold(hostname, w.Hostname) {
+			return false, err
+		}
+	}
+	if w.OS != "" && !strings.EqualFold(runtime.GOOS, w.OS) {
+		return false, nil
+	}
+	if w.Env != "" {
+		pair := strings.SplitN(w.Env, "=", 2)
+		if env := os.Getenv(pair[0]); env == "" || (len(pair) == 2 && env != pair[1]) {
+			return false, nil
+		}
+	}
+	if w.Changed && !changed {
+		return false, nil
+	}
+	return true, nil
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ahui2016/gof/model"
+	"github.com/ahui2016/gof/recipes"
+	"github.com/ahui2016/gof/util"
+	yaml3 "gopkg.in/yaml.v3"
+)
+
+// runNew 以问答的方式生成一个任务文件，用法: gof new -r RECIPE [-f gof.yaml]
+// 逐一询问 workdir, recipe 的每个 option 以及 names, 然后写入带注释的任务文件（默认为 gof.yaml），
+// 如果该文件已存在则拒绝覆盖。
+// 回答里的路径都是相对于当前文件夹的，写入文件时会转换为相对于 workdir (workdir 本身则相对于任务文件所在的文件夹)。
+func runNew() error {
+	if *recipe == "" {
+		return fmt.Errorf("usage: gof new -r RECIPE [-f gof.yaml]")
+	}
+	output := *config
+	if output == "" {
+		output = "gof.yaml"
+	}
+	if model.FormatOf(output) != model.FormatYAML {
+		return fmt.Errorf("gof new only writes YAML files: %s", output)
+	}
+	exists, err := util.PathIsExist(output)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("file exists: %s", output)
+	}
+	v := getRecipe(*recipe)
+	w := wizard{in: bufio.NewReader(os.Stdin), out: os.Stdout}
+	if err := w.checkDir(filepath.Dir(output)); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w.out, "Create %s with recipe %s\n", output, v.Name())
+	workdir, err := w.askWorkdir()
+	if err != nil {
+		return err
+	}
+	infos := recipes.Describe(v)
+	pathOptions := model.PathOptions(v.Name())
+	options := make(recipes.Options)
+	for _, info := range infos {
+		isPath := util.StrIndex(pathOptions, info.Key) >= 0
+		value, err := w.askOption(info, isPath)
+		if err != nil {
+			return err
+		}
+		if isPath && value != "" {
+			value = relPaths([]string{value}, workdir)[0]
+		}
+		options[info.Key] = value
+	}
+	names, err := w.askNames()
+	if err != nil {
+		return err
+	}
+
+	outputDir, err := filepath.Abs(filepath.Dir(output))
+	if err != nil {
+		return err
+	}
+	task := model.Task{Recipe: v.Name(), Options: options, Names: relPaths(names, workdir)}
+	blob, err := newTaskFile(v, infos, task, relPaths([]string{workdir}, outputDir)[0])
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(blob); err != nil {
+		return util.WrapErrors(err, f.Close())
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	// 像执行时一样加载并检查新文件（相同的路径解析与工作目录，不会修改文件）。
+	// 只是检查一下，即使有错误也保留该文件，因为有些文件可能要等到执行时才会存在。
+	err = quietly(func() error {
+		all, err := model.LoadTasks(output, model.FormatYAML, "")
+		if err != nil {
+			return err
+		}
+		return all.ExecAll(false)
+	})
+	if err != nil {
+		fmt.Fprintf(w.out, "warning: %v\n", err)
+	}
+	fmt.Fprintf(w.out, "%s is created, run it with: gof -f %s\n", output, output)
+	return nil
+}
+
+// wizard 负责 gof new 的问答。
+type wizard struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// ask 显示提示并读取一行输入（已去除首尾空白）。
+// 输入结束 (EOF) 时，如果已读到内容则返回该内容，否则返回 io.ErrUnexpectedEOF.
+func (w wizard) ask(prompt string) (string, error) {
+	fmt.Fprint(w.out, prompt)
+	line, err := w.in.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	if err == io.EOF {
+		return "", io.ErrUnexpectedEOF
+	}
+	return strings.TrimSpace(line), err
+}
+
+// checkDir 检查任务文件所在的文件夹 dir 是否存在，如果不存在则询问是否创建。
+func (w wizard) checkDir(dir string) error {
+	exists, err := util.PathIsExist(dir)
+	if err != nil || exists {
+		return err
+	}
+	answer, err := w.ask(fmt.Sprintf("%s does not exist, create it? [y/N]: ", dir))
+	if err != nil {
+		return err
+	}
+	if strings.ToLower(answer) != "y" {
+		return fmt.Errorf("the folder does not exist: %s", dir)
+	}
+	return os.MkdirAll(dir, 0755)
+}
+
+// askWorkdir 询问执行任务时的工作目录，直接回车则采用当前文件夹。返回绝对路径。
+func (w wizard) askWorkdir() (string, error) {
+	fmt.Fprintln(w.out, "\nworkdir: the working folder of the task, relative to the current folder")
+	for {
+		dir, err := w.ask("workdir [.]: ")
+		if err != nil {
+			return "", err
+		}
+		if dir == "" {
+			dir = "."
+		}
+		if dir, err = w.checkPath(dir); err != nil {
+			return "", err
+		}
+		if dir != "" {
+			return filepath.Abs(dir)
+		}
+	}
+}
+
+// askOption 询问一个 option 的值，直接回车则采用默认值。
+// 如果 option 有可选值，则必须从中选择；如果是路径，则会检查该路径是否存在。
+func (w wizard) askOption(info recipes.OptionInfo, isPath bool) (string, error) {
+	fmt.Fprintf(w.out, "\n%s: %s\n", info.Key, info.Desc)
+	if len(info.Enum) > 0 {
+		fmt.Fprintf(w.out, "  allowed values: %s\n", strings.Join(info.Enum, ", "))
+	}
+	for {
+		value, err := w.ask(fmt.Sprintf("%s [%s]: ", info.Key, info.Default))
+		if err != nil {
+			return "", err
+		}
+		if value == "" {
+			value = info.Default
+		}
+		if len(info.Enum) > 0 && util.StrIndex(info.Enum, value) < 0 {
+			fmt.Fprintf(w.out, "  should be one of: %s\n", strings.Join(info.Enum, ", "))
+			continue
+		}
+		if isPath && value != "" {
+			if value, err = w.checkPath(value); err != nil {
+				return "", err
+			}
+			if value == "" {
+				continue
+			}
+		}
+		return value, nil
+	}
+}
+
+// askNames 逐一询问 names, 直接回车表示结束。
+// 以 ? 结尾的输入用于列出匹配的路径，比如 "src/?" 会列出 src 文件夹里的文件。
+func (w wizard) askNames() (names []string, err error) {
+	fmt.Fprintln(w.out, "\nnames: one file/folder per line, empty line to finish, end with ? to list matching paths")
+	for {
+		name, err := w.ask(fmt.Sprintf("name %d: ", len(names)+1))
+		if err == io.ErrUnexpectedEOF {
+			return names, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if name == "" {
+			return names, nil
+		}
+		if strings.HasSuffix(name, "?") {
+			w.listPaths(strings.TrimSuffix(name, "?"))
+			continue
+		}
+		if name, err = w.checkPath(name); err != nil {
+			return nil, err
+		}
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+}
+
+// listPaths 列出以 prefix 开头的路径（文件夹以 / 结尾）。
+func (w wizard) listPaths(prefix string) {
+	matches, _ := filepath.Glob(prefix + "*")
+	if len(matches) == 0 {
+		fmt.Fprintln(w.out, "  (no match)")
+	}
+	for _, match := range matches {
+		if info, err := os.Stat(match); err == nil && info.IsDir() {
+			match += "/"
+		}
+		fmt.Fprintln(w.out, "  "+filepath.ToSlash(match))
+	}
+}
+
+// checkPath 检查 name 是否存在，如果不存在则询问是否仍然采用。
+// 返回空字符串表示不采用（需要重新输入）。
+func (w wizard) checkPath(name string) (string, error) {
+	exists, err := util.PathIsExist(name)
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		return name, nil
+	}
+	answer, err := w.ask(fmt.Sprintf("  %s does not exist, use it anyway? [y/N]: ", name))
+	if err != nil {
+		return "", err
+	}
+	if strings.ToLower(answer) == "y" {
+		return name, nil
+	}
+	return "", nil
+}
+
+// relPaths 把相对于当前文件夹的 names 转换为相对于文件夹 dir (绝对路径),
+// 因为新生成的任务文件采用 paths-relative-to: yaml (参见 model.SchemaVersion)。
+func relPaths(names []string, dir string) []string {
+	rel := make([]string, len(names))
+	for i, name := range names {
+		rel[i] = name
+		abs, err := filepath.Abs(name)
+		if err != nil {
+			continue
+		}
+		if r, err := filepath.Rel(dir, abs); err == nil {
+			rel[i] = filepath.ToSlash(r)
+		}
+	}
+	return rel
+}
+
+// newTaskFile 生成带注释的 YAML 任务文件：开头是 recipe 的帮助信息，每个 option 后面是其说明。
+func newTaskFile(v recipes.Recipe, infos []recipes.OptionInfo, task model.Task, workdir string) ([]byte, error) {
+	tasks := model.Tasks{
+		Version:  model.SchemaVersion,
+		Workdir:  workdir,
+		AllTasks: []model.Task{task},
+	}
+	var root yaml3.Node
+	if err := root.Encode(tasks); err != nil {
+		return nil, err
+	}
+	optionsNode := model.MapGet(model.MapGet(&root, "all-tasks").Content[0], "options")
+	for i := 0; optionsNode != nil && i < len(optionsNode.Content); i += 2 {
+		for _, info := range infos {
+			if info.Key == optionsNode.Content[i].Value && info.Desc != "" {
+				optionsNode.Content[i+1].LineComment = info.Desc
+			}
+		}
+	}
+
+	var header []string
+	header = append(header, fmt.Sprintf("# generated by: gof new -r %s", v.Name()), "#")
+	for _, line := range strings.Split(strings.Trim(v.Help(), "\n"), "\n") {
+		header = append(header, strings.TrimRight("# "+line, " "))
+	}
+	doc := &yaml3.Node{Kind: yaml3.DocumentNode, HeadComment: strings.Join(header, "\n"), Content: []*yaml3.Node{&root}}
+
+	var buf bytes.Buffer
+	encoder := yaml3.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, err
+	}
+	err := encoder.Close()
+	return buf.Bytes(), err
+}
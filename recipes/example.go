@@ -0,0 +1,41 @@
+package recipes
+
+import "time"
+
+// ExampleModTime 是例子里没有指定修改日期的文件的修改日期。
+var ExampleModTime = time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// ExampleFile 描述例子里的一个文件或文件夹。
+type ExampleFile struct {
+	Dir     bool      // 是否文件夹，文件夹不需要 Content 与 ModTime
+	Content string    // 文件内容
+	ModTime time.Time // 修改日期，初始的文件夹里省略时采用 ExampleModTime, 预期的文件夹里省略时不检查
+}
+
+// ExampleTree 描述一个文件夹的内容，key 是相对路径（用 / 分隔），上层文件夹可以省略。
+type ExampleTree map[string]ExampleFile
+
+// Example 是 recipe 的一个可运行的例子：在初始的文件夹 Before 里执行一个任务，执行后的文件夹应该与 After 相同。
+// 用 gof selftest 可以在临时文件夹里运行全部例子 (参见 package recipetest)。
+type Example struct {
+	Name    string   // 例子的名称，用于显示测试结果
+	Names   []string // 任务的 names, 相对路径是相对于 Workdir
+	Options Options  // 任务的 options, 省略的 option 采用默认值
+	Workdir string   // 任务的工作目录，相对于 Before 所在的文件夹，空字符串表示该文件夹本身
+
+	Before ExampleTree // 初始的文件夹
+	After  ExampleTree // 预期执行后的文件夹，nil 表示不检查
+
+	// 预期 recipe 报告的操作 (只对比 Kind, Src, Dest)，路径是相对于 Before 所在的文件夹（用 / 分隔）。
+	// nil 表示不检查，空切片表示预期没有任何操作。
+	Ops []Op
+
+	// 预期执行任务时的错误信息（包含该字符串即可），空字符串表示预期没有错误。
+	WantErr string
+}
+
+// Exampler 是一个可选的接口，用于提供 recipe 的可运行的例子 (gof selftest)。
+// 建议每个 recipe 都提供例子，使用者在处理真实的文件之前，可以先确认 recipe 在自己的电脑上能正常运行。
+type Exampler interface {
+	Examples() []Example
+}
@@ -3,8 +3,10 @@ package recipes
 import (
 	"fmt"
 	"log"
+	"sort"
 	"strings"
 
+	"github.com/ahui2016/gof/filesys"
 	"github.com/ahui2016/gof/util"
 )
 
@@ -43,19 +45,209 @@ type Recipe interface {
 	Exec() error
 }
 
+// Lister 是一个可选的接口。
+// 实现了 Lister 的 recipe 可以在 Exec 之后报告它处理过的文件（如果是 dry run, 则报告将要处理的文件），
+// 用于 -print-names 等功能。
+type Lister interface {
+	Affected() []string
+}
+
+// PathOptioner 是一个可选的接口，用于声明哪些 options 的值是文件路径。
+// 当 YAML 里的相对路径需要被解析时（参见 paths-relative-to）, 这些 options 也会被一起处理。
+type PathOptioner interface {
+	PathOptions() []string
+}
+
+// Scoper 是一个可选的接口，用于声明 recipe 的目标文件夹与源头文件（必须在 Validate 之后调用）。
+// 执行任务前会据此进行安全检查，比如删除操作只能在目标文件夹内进行，源头文件夹为空时拒绝删除等（参见 model.Safety）。
+type Scoper interface {
+	Target() string
+	Sources() []string
+}
+
+// FSSetter 是一个可选的接口。实现了 FSSetter 的 recipe 通过注入的 filesys.FS 访问文件系统。
+// 框架在 Refresh 之后注入文件系统：执行 Prepare, Validate, Plan 以及 dry run 时注入的是
+// filesys.ReadOnly, 任何修改都会返回错误，以确保这些步骤不会修改文件；真正执行 Exec 时才注入可修改的文件系统。
+type FSSetter interface {
+	SetFS(fsys filesys.FS)
+}
+
+// withFS 可以嵌入到 recipe 里，以实现 FSSetter 接口。
+type withFS struct {
+	fsys filesys.FS
+}
+
+func (w *withFS) SetFS(fsys filesys.FS) {
+	w.fsys = fsys
+}
+
+// fs 返回注入的文件系统，未注入时返回 filesys.OS.
+func (w *withFS) fs() filesys.FS {
+	if w.fsys == nil {
+		return filesys.OS{}
+	}
+	return w.fsys
+}
+
+// OptionInfo 描述一个 option 的默认值、可选值与用途，用于生成 JSON Schema 等。
+type OptionInfo struct {
+	Key     string
+	Default string
+	Enum    []string // 可选值，nil 表示不限
+	Desc    string   // 简短说明
+}
+
+// Describer 是一个可选的接口，用于描述 recipe 的每个 option.
+type Describer interface {
+	Describe() []OptionInfo
+}
+
+// Describe 返回 recipe 的全部 options 的描述。
+// 如果 recipe 没有实现 Describer, 则根据 Default() 生成描述（默认值为 yes/no 的视为只能选 yes/no）。
+func Describe(recipe Recipe) []OptionInfo {
+	if v, ok := recipe.(Describer); ok {
+		return v.Describe()
+	}
+	var infos []OptionInfo
+	for k, v := range recipe.Default() {
+		info := OptionInfo{Key: k, Default: v}
+		if v == "yes" || v == "no" {
+			info.Enum = yesNo
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Key < infos[j].Key
+	})
+	return infos
+}
+
+// OptionChange 记录一个 option 的改名或删除，用于更新旧的任务文件 (gof migrate)。
+type OptionChange struct {
+	Old  string
+	New  string // 新的名称，空字符串表示该 option 已被删除
+	Note string // 简短说明，比如删除的原因或替代方法
+}
+
+// Migrator 是一个可选的接口，用于声明已改名或已删除的 options.
+// 加载旧的任务文件时，已改名的 option 会被自动转换为新的名称，已删除的 option 会被忽略，并且都会显示警告。
+type Migrator interface {
+	OptionChanges() []OptionChange
+}
+
+// Names 返回全部已注册的 recipe 的名称（已排序）。
+func Names() []string {
+	var names []string
+	for name := range Get {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// yesNo 是 yes/no 类 option 的可选值。
+var yesNo = []string{"yes", "no"}
+
+// Aliaser 是一个可选的接口，用于声明 recipe 的别名。
+// Aliases 是普通的别名（比如简写）；DeprecatedNames 是已弃用的名称（比如 recipe 改名前的名称），
+// 使用已弃用的名称时仍然有效，但会显示警告。
+type Aliaser interface {
+	Aliases() []string
+	DeprecatedNames() []string
+}
+
+// alias 记录一个别名对应的 recipe 名称。
+type alias struct {
+	target     string
+	deprecated bool
+}
+
 var Get = make(map[string]Recipe)
 
+// aliases 的 key 是别名, value 记录了该别名对应的 recipe.
+var aliases = make(map[string]alias)
+
+// Register 注册 recipe 及其别名 (参见 Aliaser)。
+// 先检查名称与全部别名，都没有被占用才会注册，因此出错时不会留下注册了一半的 recipe.
 func Register(recipes ...Recipe) error {
 	for _, recipe := range recipes {
-		_, ok := Get[recipe.Name()]
-		if ok {
-			return fmt.Errorf("%s already exists", recipe.Name())
+		names := []string{recipe.Name()}
+		var aliasNames, deprecatedNames []string
+		if v, ok := recipe.(Aliaser); ok {
+			aliasNames, deprecatedNames = v.Aliases(), v.DeprecatedNames()
 		}
+		names = append(names, aliasNames...)
+		names = append(names, deprecatedNames...)
+		seen := make(map[string]bool)
+		for _, name := range names {
+			if err := checkName(name); err != nil {
+				return err
+			}
+			if seen[name] {
+				return fmt.Errorf("%s: duplicate name %s", recipe.Name(), name)
+			}
+			seen[name] = true
+		}
+
 		Get[recipe.Name()] = recipe
+		for _, name := range aliasNames {
+			aliases[name] = alias{target: recipe.Name()}
+		}
+		for _, name := range deprecatedNames {
+			aliases[name] = alias{target: recipe.Name(), deprecated: true}
+		}
+	}
+	return nil
+}
+
+// checkName 检查 name 是否已被其它 recipe 或别名占用。
+func checkName(name string) error {
+	_, ok1 := Get[name]
+	_, ok2 := aliases[name]
+	if ok1 || ok2 {
+		return fmt.Errorf("%s already exists", name)
 	}
 	return nil
 }
 
+// Resolve 返回 name 对应的 recipe 名称（name 可以是别名）。
+// 如果 name 是已弃用的名称，则 warning 会提示改用新的名称。
+// 如果找不到 name, 则原样返回 name.
+func Resolve(name string) (canonical, warning string) {
+	a, ok := aliases[name]
+	if !ok {
+		return name, ""
+	}
+	if a.deprecated {
+		warning = fmt.Sprintf("recipe %q is deprecated, use %q instead", name, a.target)
+	}
+	return a.target, warning
+}
+
+// Lookup 根据名称（或别名）查找 recipe.
+func Lookup(name string) (Recipe, bool) {
+	canonical, _ := Resolve(name)
+	recipe, ok := Get[canonical]
+	return recipe, ok
+}
+
+// AliasesOf 返回 recipe 的别名与已弃用的名称（已排序）。
+func AliasesOf(name string) (names, deprecated []string) {
+	for k, v := range aliases {
+		if v.target != name {
+			continue
+		}
+		if v.deprecated {
+			deprecated = append(deprecated, k)
+		} else {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	sort.Strings(deprecated)
+	return
+}
+
 // namesLimit 清除 names 里的空字符串，并且限定其上下限。
 func namesLimit(names []string, min, max int) ([]string, error) {
 	names = util.StrSliceFilter(names, func(name string) bool {
@@ -3,13 +3,13 @@ package recipes
 import (
 	"fmt"
 	"io/fs"
-	"io/ioutil"
-	"os"
 	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/ahui2016/gof/filesys"
 	"github.com/ahui2016/gof/util"
 )
 
@@ -21,11 +21,13 @@ import (
 // MoveNewFiles 实现了 Recipe 接口，用于把一个文件夹内的 n 个最新文件移动到另一个文件夹。
 // 只能处理一个文件夹内的第一层文件，不会递归搜索子文件夹。
 type MoveNewFiles struct {
+	withFS
 	names   []string // names[0] 是目标文件夹, names[1] 是源头文件夹
 	options Options  // 暂存 options 待处理
 	n       int      // 移动多少个修改日期最新的文件
 	suffix  string   // 指定文件名的结尾，空字符串表示不限
 	dryRun  bool     // 如果 dryRun 为 true, 则只显示信息，不实际执行
+	moved   []string // 已移动（或 dry run 时将要移动）的源头文件
 }
 
 func (mv *MoveNewFiles) Name() string {
@@ -40,8 +42,8 @@ func (mv *MoveNewFiles) Help() string {
     suffix: ""     # 指定文件名的结尾，空字符串表示不限
     dry-run: "yes" # 设为 yes 时只显示信息；设为 no 时才会实际执行
   names:
-  - .\dest\        # 第一个是目标文件夹
-  - .\src\         # 第二个是源头文件夹
+  - ./dest/        # 第一个是目标文件夹
+  - ./src/         # 第二个是源头文件夹
 
 # 建议先 dry run, 如果有重名文件会提示 "skip"。
 # 确认没问题后再把 dry run 的值改为 no。
@@ -60,6 +62,14 @@ func (mv *MoveNewFiles) Default() Options {
 	}
 }
 
+func (mv *MoveNewFiles) Describe() []OptionInfo {
+	return []OptionInfo{
+		{Key: "n", Default: "1", Desc: "移动多少个文件"},
+		{Key: "suffix", Default: "", Desc: "指定文件名的结尾，空字符串表示不限"},
+		{Key: "dry-run", Default: "yes", Enum: yesNo, Desc: "设为 yes 时只显示信息；设为 no 时才会实际执行"},
+	}
+}
+
 // Perpare 初始化一些项目，但 mv.n 则需要在 Validate 里初始化。
 func (mv *MoveNewFiles) Prepare(names []string, options Options) {
 	mv.names = names
@@ -96,7 +106,7 @@ func (mv *MoveNewFiles) Exec() error {
 	fmt.Printf("\nMove files from [%s] to [%s]\n", mv.names[1], mv.names[0])
 	for _, info := range infos {
 		target := filepath.Join(mv.names[0], info.Name())
-		exists, err := util.PathIsExist(target)
+		exists, err := filesys.PathIsExist(mv.fs(), target)
 		if err != nil {
 			return err
 		}
@@ -106,16 +116,17 @@ func (mv *MoveNewFiles) Exec() error {
 		} else {
 			fmt.Printf("-- move %s\n", info.Name())
 		}
+		src := filepath.Join(mv.names[1], info.Name())
+		mv.moved = append(mv.moved, src)
 		if mv.dryRun {
 			continue
 		}
 
-		src := filepath.Join(mv.names[1], info.Name())
-		if err := os.Rename(src, target); err != nil {
-			if err := util.CopyFile(target, src); err != nil {
+		if err := mv.fs().Rename(src, target); err != nil {
+			if err := filesys.CopyFile(mv.fs(), target, src); err != nil {
 				return err
 			}
-			if err := os.Remove(src); err != nil {
+			if err := mv.fs().Remove(src); err != nil {
 				return err
 			}
 		}
@@ -123,11 +134,71 @@ func (mv *MoveNewFiles) Exec() error {
 	return nil
 }
 
+// Plan 返回将要执行的操作（目标文件夹里已有同名文件的会被跳过）。
+func (mv *MoveNewFiles) Plan() (ops []Op, err error) {
+	infos, err := mv.getNewFiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range infos {
+		target := filepath.Join(mv.names[0], info.Name())
+		exists, err := filesys.PathIsExist(mv.fs(), target)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			continue
+		}
+		src := filepath.Join(mv.names[1], info.Name())
+		ops = append(ops, Op{Kind: OpMove, Src: src, Dest: target, Size: info.Size()})
+	}
+	return ops, nil
+}
+
+// Affected 返回已移动（或 dry run 时将要移动）的源头文件。
+func (mv *MoveNewFiles) Affected() []string {
+	return mv.moved
+}
+
+// Examples 移动最新的两个 .jpg 文件，目标文件夹里已有同名文件的会被跳过。
+func (mv *MoveNewFiles) Examples() []Example {
+	return []Example{{
+		Name:    "move-new-files",
+		Names:   []string{"dest", "inbox"},
+		Options: Options{"n": "2", "suffix": ".jpg", "dry-run": "no"},
+		Before: ExampleTree{
+			"inbox/1.jpg": {Content: "1", ModTime: ExampleModTime.Add(1 * time.Hour)},
+			"inbox/2.jpg": {Content: "2", ModTime: ExampleModTime.Add(2 * time.Hour)},
+			"inbox/3.jpg": {Content: "3", ModTime: ExampleModTime.Add(3 * time.Hour)},
+			"inbox/4.txt": {Content: "4", ModTime: ExampleModTime.Add(4 * time.Hour)},
+			"dest/2.jpg":  {Content: "old"},
+		},
+		After: ExampleTree{
+			"inbox/1.jpg": {Content: "1"},
+			"inbox/2.jpg": {Content: "2"},
+			"inbox/4.txt": {Content: "4"},
+			"dest/2.jpg":  {Content: "old"},
+			"dest/3.jpg":  {Content: "3"},
+		},
+		Ops: []Op{
+			{Kind: OpMove, Src: "inbox/3.jpg", Dest: "dest/3.jpg"},
+		},
+	}}
+}
+
 func (mv *MoveNewFiles) getNewFiles() ([]fs.FileInfo, error) {
-	files, err := ioutil.ReadDir(mv.names[1])
+	entries, err := mv.fs().ReadDir(mv.names[1])
 	if err != nil {
 		return nil, err
 	}
+	files := make([]fs.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, info)
+	}
 	// 只要普通文件，不要文件夹，如果指定了后缀，则只返回指定后缀的文件。
 	files = mv.filter(files, func(info fs.FileInfo) bool {
 		if !info.Mode().IsRegular() {
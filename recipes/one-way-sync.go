@@ -9,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ahui2016/gof/filesys"
 	"github.com/ahui2016/gof/util"
 )
 
@@ -24,6 +25,7 @@ import (
 // 对于 update 的情况，可选择是否对比日期、是否对比内容（至少对比其中一项）。
 // 如果 dryRun 为 true, 则只显示信息，不实际执行。
 type OneWaySync struct {
+	withFS
 	names     []string
 	targetDir string
 	srcFiles  []string
@@ -34,12 +36,23 @@ type OneWaySync struct {
 	byDate    bool
 	byContent bool
 	verbose   bool
+	ops       []Op // 遍历文件时记录的操作 (参见 Plan)
+	planned   bool // 是否已调用 Plan, 如果是则 Exec 直接执行 o.ops, 不再遍历文件
 }
 
 func (o *OneWaySync) Name() string {
 	return "one-way-sync"
 }
 
+// Aliases 返回别名，可以用 sync 代替 one-way-sync.
+func (o *OneWaySync) Aliases() []string {
+	return []string{"sync"}
+}
+
+func (o *OneWaySync) DeprecatedNames() []string {
+	return nil
+}
+
 func (o *OneWaySync) Help() string {
 	return `
 - recipe: one-way-sync # 单向同步
@@ -53,18 +66,30 @@ func (o *OneWaySync) Help() string {
     by-content: "yes"  # 是否对比文件的内容
     verbose: "yes"     # 如果设为 no, 则在实际执行后不会显示详细信息
   names:
-  - .\dest\            # 第一个是目标文件夹
-  - .\folder\          # 从第二个开始是源头文件或文件夹
-  - .\file.txt
+  - ./dest/            # 第一个是目标文件夹
+  - ./folder/          # 从第二个开始是源头文件或文件夹
+  - ./file.txt
 
 # 注意，names 里的第一个元素是目标文件夹，可使用绝对目录或相对目录。
-# 从 names 的第二个元素开始是源头文件或文件夹，只能使用相对目录。
-# 使用本 recipe 时，必须先进入源头文件夹，在源头文件夹内执行 gof 命令。
+# 从 names 的第二个元素开始是源头文件或文件夹，只能使用相对目录（或位于工作目录内的绝对目录）。
+# 使用本 recipe 时，必须先进入源头文件夹，在源头文件夹内执行 gof 命令，
+# 或者在 YAML 文件里用 workdir 指定源头文件夹（相对于 YAML 文件所在的文件夹）。
 `
 }
 
 func (o *OneWaySync) Refresh() {
 	*o = *new(OneWaySync)
+	o.resetLists()
+}
+
+// resetLists 清空 ows_ 开头的列表以及 o.ops
+func (o *OneWaySync) resetLists() {
+	o.ops = nil
+	ows_addList = nil
+	ows_updateList = nil
+	ows_delList = nil
+	ows_added = nil
+	ows_deleted = nil
 }
 
 func (o *OneWaySync) Default() map[string]string {
@@ -79,6 +104,18 @@ func (o *OneWaySync) Default() map[string]string {
 	}
 }
 
+func (o *OneWaySync) Describe() []OptionInfo {
+	return []OptionInfo{
+		{Key: "dry-run", Default: "yes", Enum: yesNo, Desc: "设为 yes 时只显示信息；设为 no 时才会实际执行"},
+		{Key: "add", Default: "yes", Enum: yesNo, Desc: "是否添加文件"},
+		{Key: "update", Default: "yes", Enum: yesNo, Desc: "是否更新文件"},
+		{Key: "delete", Default: "no", Enum: yesNo, Desc: "是否删除文件"},
+		{Key: "by-date", Default: "no", Enum: yesNo, Desc: "是否对比文件的修改日期"},
+		{Key: "by-content", Default: "yes", Enum: yesNo, Desc: "是否对比文件的内容"},
+		{Key: "verbose", Default: "yes", Enum: yesNo, Desc: "如果设为 no, 则在实际执行后不会显示详细信息"},
+	}
+}
+
 // Perpare 初始化一些项目，但 targetDir 与 srcFiles 则需要在 Validate 里初始化。
 func (o *OneWaySync) Prepare(names []string, options map[string]string) {
 	o.names = names
@@ -108,13 +145,13 @@ func (o *OneWaySync) Validate() (err error) {
 
 	// 确保每个文件/文件名都真实存在
 	for i := range o.names {
-		if err := util.FindFile(o.names[i]); err != nil {
+		if err := filesys.FindFile(o.fs(), o.names[i]); err != nil {
 			return err
 		}
 	}
 
 	// 确保 o.names[0] 是文件夹
-	info, err := os.Lstat(o.names[0])
+	info, err := o.fs().Lstat(o.names[0])
 	if err != nil {
 		return err
 	}
@@ -124,8 +161,33 @@ func (o *OneWaySync) Validate() (err error) {
 
 	// 初始化
 	o.targetDir = o.names[0]
-	o.srcFiles = o.names[1:]
-	return nil
+	o.srcFiles, err = o.relSrcFiles(o.names[1:])
+	return err
+}
+
+// relSrcFiles 把源头文件中的绝对路径转换为相对于当前文件夹的相对路径，
+// 因为源头文件的路径会被直接拼接到 targetDir 之后。
+func (o *OneWaySync) relSrcFiles(names []string) ([]string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	srcFiles := make([]string, len(names))
+	for i, name := range names {
+		if !filepath.IsAbs(name) {
+			srcFiles[i] = name
+			continue
+		}
+		rel, err := filepath.Rel(cwd, name)
+		if err != nil {
+			return nil, err
+		}
+		if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("the source is not in the working folder: %s", name)
+		}
+		srcFiles[i] = rel
+	}
+	return srcFiles, nil
 }
 
 var (
@@ -137,15 +199,14 @@ var (
 )
 
 func (o *OneWaySync) Exec() error {
-	// 处理 add 和 update
-	for _, srcName := range o.srcFiles {
-		if err := o.walk(srcName); err != nil {
-			return err
+	if o.planned && !o.dryRun {
+		// 刚执行过 Plan (比如执行前的安全检查), 直接执行其列出的操作，以免再次遍历文件与对比内容。
+		for _, op := range o.enabledOps() {
+			if err := op.Exec(o.fs()); err != nil {
+				return err
+			}
 		}
-	}
-
-	// 处理 delete
-	if err := o.walkDelete(); err != nil {
+	} else if err := o.walkAll(); err != nil {
 		return err
 	}
 
@@ -173,7 +234,7 @@ func (o *OneWaySync) Exec() error {
 }
 
 func (o *OneWaySync) walkDelete() error {
-	return filepath.WalkDir(o.targetDir, func(name string, d fs.DirEntry, err error) error {
+	return o.fs().WalkDir(o.targetDir, func(name string, d fs.DirEntry, err error) error {
 		if err != nil {
 			log.Print("Error in WalkDir")
 			return err
@@ -190,7 +251,7 @@ func (o *OneWaySync) walkDelete() error {
 		if err != nil {
 			return err
 		}
-		notExist, err := util.PathIsNotExist(srcPath)
+		notExist, err := filesys.PathIsNotExist(o.fs(), srcPath)
 		if err != nil {
 			return err
 		}
@@ -198,13 +259,26 @@ func (o *OneWaySync) walkDelete() error {
 		if notExist {
 			// 标记需要删除的文件或文件夹
 			ows_delList = append(ows_delList, name)
+			op := Op{Kind: OpDelete, Dest: name}
 			// 把文件夹标记为已删除，以便跳过处理其内容
 			if d.IsDir() {
 				ows_deleted = append(ows_deleted, name)
+			} else {
+				info, err := d.Info()
+				if err != nil {
+					return err
+				}
+				op.Size = info.Size()
 			}
-			// 实际删除文件或文件夹
+			o.ops = append(o.ops, op)
+			// 实际删除文件或文件夹（已删除的文件夹不能再进入）
 			if !o.dryRun && o.delete {
-				return os.RemoveAll(name)
+				if err := o.fs().RemoveAll(name); err != nil {
+					return err
+				}
+				if d.IsDir() {
+					return fs.SkipDir
+				}
 			}
 		}
 		return nil
@@ -212,14 +286,14 @@ func (o *OneWaySync) walkDelete() error {
 }
 
 func (o *OneWaySync) walk(root string) error {
-	return filepath.WalkDir(root, func(name string, d fs.DirEntry, err error) error {
+	return o.fs().WalkDir(root, func(name string, d fs.DirEntry, err error) error {
 		if err != nil {
 			log.Print("Error in WalkDir")
 			return err
 		}
 
 		targetPath := filepath.Join(o.targetDir, name)
-		notExists, err := util.PathIsNotExist(targetPath)
+		notExists, err := filesys.PathIsNotExist(o.fs(), targetPath)
 		if err != nil {
 			return err
 		}
@@ -237,6 +311,9 @@ func (o *OneWaySync) walk(root string) error {
 			}
 			if d.IsDir() {
 				ows_added = append(ows_added, targetPath)
+				o.ops = append(o.ops, Op{Kind: OpMkdir, Dest: targetPath})
+			} else {
+				o.ops = append(o.ops, Op{Kind: OpAdd, Src: name, Dest: targetPath, Size: srcInfo.Size()})
 			}
 		}
 		// 实际执行复制文件
@@ -244,7 +321,7 @@ func (o *OneWaySync) walk(root string) error {
 			// 如果是文件夹
 			if d.IsDir() {
 				if notExists {
-					if err := os.Mkdir(targetPath, os.ModePerm); err != nil {
+					if err := o.fs().Mkdir(targetPath, os.ModePerm); err != nil {
 						return err
 					}
 				}
@@ -264,7 +341,7 @@ func (o *OneWaySync) walk(root string) error {
 			return nil
 		}
 		isNeedUpdate := false
-		destInfo, err := os.Lstat(targetPath)
+		destInfo, err := o.fs().Lstat(targetPath)
 		if err != nil {
 			return err
 		}
@@ -277,11 +354,11 @@ func (o *OneWaySync) walk(root string) error {
 		}
 		// 对比内容
 		if o.byContent {
-			srcSum, err := util.FileSha256Hex(name)
+			srcSum, err := util.FSFileSha256Hex(o.fs(), name)
 			if err != nil {
 				return err
 			}
-			destSum, err := util.FileSha256Hex(targetPath)
+			destSum, err := util.FSFileSha256Hex(o.fs(), targetPath)
 			if err != nil {
 				return err
 			}
@@ -293,6 +370,7 @@ func (o *OneWaySync) walk(root string) error {
 		if isNeedUpdate {
 			// 标记即将更新的文件
 			ows_updateList = append(ows_updateList, targetPath)
+			o.ops = append(o.ops, Op{Kind: OpUpdate, Src: name, Dest: targetPath, Size: srcInfo.Size()})
 			// 实际执行更新文件
 			if !o.dryRun && o.update {
 				if err := o.copy_setTime(targetPath, name, srcInfo); err != nil {
@@ -304,11 +382,169 @@ func (o *OneWaySync) walk(root string) error {
 	})
 }
 
+// walkAll 清空之前遍历文件时的记录，然后处理 add, update 与 delete.
+func (o *OneWaySync) walkAll() error {
+	o.resetLists()
+
+	// 处理 add 和 update
+	for _, srcName := range o.srcFiles {
+		if err := o.walk(srcName); err != nil {
+			return err
+		}
+	}
+
+	// 处理 delete
+	return o.walkDelete()
+}
+
+// Plan 以 dry run 的方式遍历文件，返回将要执行的操作，
+// 只包括 add/update/delete 中被设为 yes 的项目。
+// 之后执行 Exec 时会直接执行这些操作，不会再次遍历文件。
+func (o *OneWaySync) Plan() ([]Op, error) {
+	dryRun := o.dryRun
+	o.dryRun = true
+	defer func() { o.dryRun = dryRun }()
+
+	o.planned = false
+	if err := o.walkAll(); err != nil {
+		return nil, err
+	}
+	o.planned = true
+	return o.enabledOps(), nil
+}
+
+// enabledOps 返回 o.ops 中 add/update/delete 被设为 yes 的操作。
+func (o *OneWaySync) enabledOps() (ops []Op) {
+	for _, op := range o.ops {
+		switch op.Kind {
+		case OpMkdir, OpAdd:
+			if !o.add {
+				continue
+			}
+		case OpUpdate:
+			if !o.update {
+				continue
+			}
+		case OpDelete:
+			if !o.delete {
+				continue
+			}
+		}
+		ops = append(ops, op)
+	}
+	return
+}
+
+// Target 返回目标文件夹。
+func (o *OneWaySync) Target() string {
+	return o.targetDir
+}
+
+// Sources 返回源头文件或文件夹。
+func (o *OneWaySync) Sources() []string {
+	return o.srcFiles
+}
+
+// Examples 的第一个例子对应 examples/one-way-sync, 第二个例子则包括删除文件与文件夹。
+func (o *OneWaySync) Examples() []Example {
+	return []Example{
+		{
+			// 在 src 文件夹里执行，不删除多余的文件
+			Name:    "one-way-sync",
+			Workdir: "src",
+			Names:   []string{"../dest/", "./aaa/", "./file1.txt"},
+			Options: Options{
+				"add":        "yes",
+				"update":     "yes",
+				"delete":     "no",
+				"by-date":    "no",
+				"by-content": "yes",
+				"dry-run":    "no",
+				"verbose":    "no",
+			},
+			Before: ExampleTree{
+				"src/aaa/a1.txt":  {Content: "a1 a1 a1"},
+				"src/aaa/a2.txt":  {Content: "a2 a2 a2"},
+				"src/file1.txt":   {Content: "111"},
+				"dest/aaa/a2.txt": {Content: "222 222 222"},
+				"dest/aaa/a3.txt": {Content: "333 333 333"},
+			},
+			After: ExampleTree{
+				"src/aaa/a1.txt":  {Content: "a1 a1 a1"},
+				"src/aaa/a2.txt":  {Content: "a2 a2 a2"},
+				"src/file1.txt":   {Content: "111"},
+				"dest/aaa/a1.txt": {Content: "a1 a1 a1", ModTime: ExampleModTime},
+				"dest/aaa/a2.txt": {Content: "a2 a2 a2", ModTime: ExampleModTime},
+				"dest/aaa/a3.txt": {Content: "333 333 333"},
+				"dest/file1.txt":  {Content: "111", ModTime: ExampleModTime},
+			},
+			Ops: []Op{
+				{Kind: OpAdd, Src: "src/aaa/a1.txt", Dest: "dest/aaa/a1.txt"},
+				{Kind: OpUpdate, Src: "src/aaa/a2.txt", Dest: "dest/aaa/a2.txt"},
+				{Kind: OpAdd, Src: "src/file1.txt", Dest: "dest/file1.txt"},
+			},
+		},
+		{
+			// 对比日期，删除多余的文件与文件夹，新增的文件夹连同其内容一起复制
+			Name:  "one-way-sync delete",
+			Names: []string{"dest", "docs"},
+			Options: Options{
+				"delete":     "yes",
+				"by-date":    "yes",
+				"by-content": "no",
+				"dry-run":    "no",
+				"verbose":    "no",
+			},
+			Before: ExampleTree{
+				"docs/a.txt":          {Content: "new", ModTime: ExampleModTime.Add(time.Hour)},
+				"docs/b.txt":          {Content: "same"},
+				"docs/sub/c.txt":      {Content: "c"},
+				"dest/docs/a.txt":     {Content: "old"},
+				"dest/docs/b.txt":     {Content: "same"},
+				"dest/docs/old.txt":   {Content: "old"},
+				"dest/docs/old/d.txt": {Content: "d"},
+			},
+			After: ExampleTree{
+				"docs/a.txt":          {Content: "new"},
+				"docs/b.txt":          {Content: "same"},
+				"docs/sub/c.txt":      {Content: "c"},
+				"dest/docs/a.txt":     {Content: "new", ModTime: ExampleModTime.Add(time.Hour)},
+				"dest/docs/b.txt":     {Content: "same"},
+				"dest/docs/sub/c.txt": {Content: "c"},
+			},
+			Ops: []Op{
+				{Kind: OpUpdate, Src: "docs/a.txt", Dest: "dest/docs/a.txt"},
+				{Kind: OpMkdir, Dest: "dest/docs/sub"},
+				{Kind: OpAdd, Src: "docs/sub/c.txt", Dest: "dest/docs/sub/c.txt"},
+				{Kind: OpDelete, Dest: "dest/docs/old"},
+				{Kind: OpDelete, Dest: "dest/docs/old.txt"},
+			},
+		},
+	}
+}
+
+// Affected 返回已处理（或 dry run 时将要处理）的文件，
+// 只包括 add/update/delete 中被设为 yes 的项目。
+func (o *OneWaySync) Affected() (names []string) {
+	if o.add {
+		names = append(names, ows_addList...)
+	}
+	if o.update {
+		names = append(names, ows_updateList...)
+	}
+	if o.delete {
+		names = append(names, ows_delList...)
+	}
+	return
+}
+
 // subOfFolders 检查 name 是否在 folders 里的任何一个文件夹中。
 // folders 是被标记为已添加或已删除的文件夹。
 func (o *OneWaySync) subOfFolders(name string, folders []string) bool {
 	for _, folder := range folders {
-		if strings.HasPrefix(name, folder) {
+		// 加上分隔符，以免把 old.txt 当作 old 文件夹里的文件。
+		prefix := strings.TrimSuffix(folder, string(filepath.Separator)) + string(filepath.Separator)
+		if strings.HasPrefix(name, prefix) {
 			return true
 		}
 	}
@@ -326,8 +562,8 @@ func (o *OneWaySync) printArray(arr []string) {
 }
 
 func (o *OneWaySync) copy_setTime(dest, src string, info fs.FileInfo) error {
-	if err := util.CopyFile(dest, src); err != nil {
+	if err := filesys.CopyFile(o.fs(), dest, src); err != nil {
 		return err
 	}
-	return os.Chtimes(dest, time.Now(), info.ModTime())
+	return o.fs().Chtimes(dest, time.Now(), info.ModTime())
 }
@@ -0,0 +1,82 @@
+package recipes
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ahui2016/gof/filesys"
+)
+
+// Op 的种类
+const (
+	OpMkdir  = "mkdir"  // 新建文件夹 Dest
+	OpAdd    = "add"    // 把 Src 复制为 Dest (Dest 原本不存在)
+	OpUpdate = "update" // 用 Src 覆盖 Dest
+	OpDelete = "delete" // 删除 Dest (如果是文件夹，则连同其内容一起删除)
+	OpMove   = "move"   // 把 Src 移动到 Dest
+	OpRename = "rename" // 把 Src 改名为 Dest (不能跨硬盘分区)
+)
+
+// Op 是一个具体的文件操作，用于 gof plan 与 gof apply 等功能。
+// Size 是涉及的文件大小 (bytes)，仅供参考。
+type Op struct {
+	Kind string `json:"kind"`
+	Src  string `json:"src,omitempty"`
+	Dest string `json:"dest"`
+	Size int64  `json:"size,omitempty"`
+}
+
+func (op Op) String() string {
+	if op.Src == "" {
+		return fmt.Sprintf("%s %s", op.Kind, op.Dest)
+	}
+	return fmt.Sprintf("%s %s -> %s", op.Kind, op.Src, op.Dest)
+}
+
+// Paths 返回 op 涉及的文件路径。
+func (op Op) Paths() []string {
+	if op.Src == "" {
+		return []string{op.Dest}
+	}
+	return []string{op.Src, op.Dest}
+}
+
+// Exec 通过 fsys 执行 op. 复制文件 (add, update) 时会保留源头文件的修改日期。
+func (op Op) Exec(fsys filesys.FS) error {
+	switch op.Kind {
+	case OpMkdir:
+		return fsys.Mkdir(op.Dest, os.ModePerm)
+	case OpAdd, OpUpdate:
+		info, err := fsys.Lstat(op.Src)
+		if err != nil {
+			return err
+		}
+		if err := filesys.CopyFile(fsys, op.Dest, op.Src); err != nil {
+			return err
+		}
+		return fsys.Chtimes(op.Dest, time.Now(), info.ModTime())
+	case OpDelete:
+		return fsys.RemoveAll(op.Dest)
+	case OpMove:
+		if err := fsys.Rename(op.Src, op.Dest); err == nil {
+			return nil
+		}
+		if err := filesys.CopyFile(fsys, op.Dest, op.Src); err != nil {
+			return err
+		}
+		return fsys.Remove(op.Src)
+	case OpRename:
+		return fsys.Rename(op.Src, op.Dest)
+	}
+	return fmt.Errorf("unknown op: %s", op.Kind)
+}
+
+// Planner 是一个可选的接口。
+// 实现了 Planner 的 recipe 可以在 Validate 之后（不需要执行 Exec）返回将要执行的具体操作，
+// 用于 gof plan 等功能。与 Validate 一样，Plan 只能读取文件信息，不可修改文件。
+// 依次执行返回的 Op 应该与执行 Exec 的效果相同。
+// 执行任务前会先调用 Plan 进行安全检查，因此 Exec 可以直接执行刚才返回的 Op, 以免重复遍历文件 (参见 OneWaySync)。
+type Planner interface {
+	Plan() ([]Op, error)
+}
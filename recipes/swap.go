@@ -3,11 +3,10 @@ package recipes
 import (
 	"fmt"
 	"log"
-	"os"
 	"path/filepath"
 	"strings"
 
-	"github.com/ahui2016/gof/util"
+	"github.com/ahui2016/gof/filesys"
 )
 
 /*
@@ -25,6 +24,7 @@ const swap_limit = 20
 // Swap 只能用于不需要移动文件的情况，比如同一个文件夹（或同一个硬盘分区）内的文件可以操作，
 // 而跨硬盘分区的文件则无法处理。
 type Swap struct {
+	withFS
 	names   []string
 	verbose bool
 }
@@ -57,6 +57,12 @@ func (s *Swap) Default() Options {
 	}
 }
 
+func (s *Swap) Describe() []OptionInfo {
+	return []OptionInfo{
+		{Key: "verbose", Default: "yes", Enum: yesNo, Desc: "显示或不显示程序执行的详细过程"},
+	}
+}
+
 func (s *Swap) Prepare(names []string, options Options) {
 	s.names = names
 	s.verbose = yesToBool(options["verbose"])
@@ -68,7 +74,7 @@ func (s *Swap) Validate() (err error) {
 		return fmt.Errorf("%s: %w", s.Name(), err)
 	}
 	for i := range s.names {
-		if err := util.FindFile(s.names[i]); err != nil {
+		if err := filesys.FindFile(s.fs(), s.names[i]); err != nil {
 			return err
 		}
 	}
@@ -88,21 +94,21 @@ func (s *Swap) Exec() error {
 		log.Printf("-- found a safe temp filename: %s", temp)
 		log.Printf("-- rename %s to %s", s.names[0], temp)
 	}
-	if err := os.Rename(s.names[0], temp); err != nil {
+	if err := s.fs().Rename(s.names[0], temp); err != nil {
 		return err
 	}
 
 	if s.verbose {
 		log.Printf("-- rename %s to %s", s.names[1], s.names[0])
 	}
-	if err := os.Rename(s.names[1], s.names[0]); err != nil {
+	if err := s.fs().Rename(s.names[1], s.names[0]); err != nil {
 		return err
 	}
 
 	if s.verbose {
 		log.Printf("-- rename %s to %s", temp, s.names[1])
 	}
-	if err := os.Rename(temp, s.names[1]); err != nil {
+	if err := s.fs().Rename(temp, s.names[1]); err != nil {
 		return err
 	}
 
@@ -110,6 +116,50 @@ func (s *Swap) Exec() error {
 	return nil
 }
 
+// Plan 返回对调文件名所需的三次改名操作。
+func (s *Swap) Plan() ([]Op, error) {
+	temp, err := s.tempName(s.names[0])
+	if err != nil {
+		return nil, err
+	}
+	return []Op{
+		{Kind: OpRename, Src: s.names[0], Dest: temp},
+		{Kind: OpRename, Src: s.names[1], Dest: s.names[0]},
+		{Kind: OpRename, Src: temp, Dest: s.names[1]},
+	}, nil
+}
+
+// Affected 返回被对调的两个文件名。
+func (s *Swap) Affected() []string {
+	return s.names
+}
+
+// Examples 对应 examples/swap 的第一个任务，由于 file11.txt 已存在，临时文件名会是 file111.txt
+func (s *Swap) Examples() []Example {
+	return []Example{{
+		Name:    "swap",
+		Names:   []string{"file1.txt", "file2.txt"},
+		Options: Options{"verbose": "no"},
+		Before: ExampleTree{
+			"file1.txt":  {Content: "111"},
+			"file2.txt":  {Content: "222"},
+			"file3.txt":  {Content: "333"},
+			"file11.txt": {Content: "I'm fine."},
+		},
+		After: ExampleTree{
+			"file1.txt":  {Content: "222"},
+			"file2.txt":  {Content: "111"},
+			"file3.txt":  {Content: "333"},
+			"file11.txt": {Content: "I'm fine."},
+		},
+		Ops: []Op{
+			{Kind: OpRename, Src: "file1.txt", Dest: "file111.txt"},
+			{Kind: OpRename, Src: "file2.txt", Dest: "file1.txt"},
+			{Kind: OpRename, Src: "file111.txt", Dest: "file2.txt"},
+		},
+	}}
+}
+
 // addSuffix 给一个文件名添加后缀，使其变成一个临时文件名。
 // 比如 abc.js 处理后应变成 abc1.js
 func (s *Swap) addSuffix(name string) string {
@@ -131,7 +181,7 @@ func (s *Swap) addSuffix(name string) string {
 func (s *Swap) tempName(name string) (string, error) {
 	for i := 0; i < swap_limit; i++ {
 		name = s.addSuffix(name)
-		ok, err := util.PathIsNotExist(name)
+		ok, err := filesys.PathIsNotExist(s.fs(), name)
 		if err != nil {
 			return "", err
 		}
@@ -0,0 +1,377 @@
+// Package recipetest 用于测试 recipe: 描述一个初始的文件夹 (Tree), 用指定的 names 与 options 执行一个任务，
+// 然后检查执行后的文件夹以及 recipe 报告的操作 (recipes.Planner) 是否与预期的相同。
+// 另外还会检查 Validate, Plan 以及 dry run 都没有修改任何文件。
+//
+// 用 Run 在磁盘上的临时文件夹里执行，用 RunMem 则在内存里 (filesys.Mem) 执行。
+// 测试用例可以写在 recipe 的 Examples 方法里 (参见 recipes.Exampler)，用 Examples 即可取得。
+package recipetest
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ahui2016/gof/filesys"
+	"github.com/ahui2016/gof/model"
+	"github.com/ahui2016/gof/recipes"
+)
+
+// File 描述 Tree 里的一个文件或文件夹。
+type File = recipes.ExampleFile
+
+// Tree 描述一个文件夹的内容，key 是相对路径（用 / 分隔），上层文件夹可以省略。
+type Tree = recipes.ExampleTree
+
+// Case 是一个测试用例：用 recipe 执行 Example 里的任务 (参见 recipes.Example)。
+type Case struct {
+	Recipe string // recipe 名称（或别名）
+	recipes.Example
+
+	// 如果不是 nil, 执行任务前由它确认将要执行的操作 (参见 model.Tasks.Confirmer)。
+	Confirmer model.Confirmer
+}
+
+// Examples 返回已注册的 recipe 的例子 (参见 recipes.Exampler)，按 recipe 名称排序。
+// name 不是空字符串时只返回该 recipe 的例子。
+func Examples(name string) (cases []Case, err error) {
+	names := recipes.Names()
+	if name != "" {
+		recipe, ok := recipes.Lookup(name)
+		if !ok {
+			return nil, fmt.Errorf("not found recipe: %s", name)
+		}
+		names = []string{recipe.Name()}
+	}
+	for _, name := range names {
+		exampler, ok := recipes.Get[name].(recipes.Exampler)
+		if !ok {
+			continue
+		}
+		for _, example := range exampler.Examples() {
+			cases = append(cases, Case{Recipe: name, Example: example})
+		}
+	}
+	return
+}
+
+// Run 在磁盘上的一个临时文件夹里执行 c, 执行后会删除该文件夹。
+func Run(c Case) error {
+	dir, err := os.MkdirTemp("", "gof-recipetest-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	// 有些系统的临时文件夹是 symlink (比如 macOS 的 /var), 需要先解析，否则无法对比操作的路径。
+	if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		return err
+	}
+	return run(filesys.OS{}, dir, filepath.Join(dir, c.Workdir), c)
+}
+
+// RunMem 在内存里执行 c, 不会读写磁盘上的文件。
+// 由于内存里的相对路径是相对于当前文件夹 (参见 filesys.Mem)，因此 Before 被放在当前文件夹，并且不支持 Workdir.
+func RunMem(c Case) error {
+	if c.Workdir != "" {
+		return fmt.Errorf("%s: RunMem does not support workdir", c.Name)
+	}
+	root, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	return run(filesys.NewMem(), root, "", c)
+}
+
+// run 在 fsys 的文件夹 root 里执行 c, workdir 是任务的工作目录（空字符串表示当前文件夹）。
+func run(fsys filesys.FS, root, workdir string, c Case) error {
+	if err := c.run(fsys, root, workdir); err != nil {
+		return fmt.Errorf("%s: %w", c.Name, err)
+	}
+	return nil
+}
+
+func (c Case) run(fsys filesys.FS, root, workdir string) error {
+	recipe, ok := recipes.Lookup(c.Recipe)
+	if !ok {
+		return fmt.Errorf("not found recipe: %s", c.Recipe)
+	}
+	if err := filesys.MkdirAll(fsys, root); err != nil {
+		return err
+	}
+	if err := WriteTree(fsys, root, c.Before); err != nil {
+		return err
+	}
+	before, err := ReadTree(fsys, root)
+	if err != nil {
+		return err
+	}
+
+	options := recipe.Default()
+	for k, v := range c.Options {
+		options[k] = v
+	}
+	tasks := func(options recipes.Options) model.Tasks {
+		return model.Tasks{FS: fsys, Workdir: workdir, Confirmer: c.Confirmer, AllTasks: []model.Task{{
+			Recipe: recipe.Name(), Names: c.Names, Options: options,
+		}}}
+	}
+	unchanged := func(step string) error {
+		now, err := ReadTree(fsys, root)
+		if err != nil {
+			return err
+		}
+		if diff := diffTrees(now, before, true); len(diff) > 0 {
+			return fmt.Errorf("%s modified files:\n  %s", step, strings.Join(diff, "\n  "))
+		}
+		return nil
+	}
+
+	// Validate 不可修改文件。如果预期有错误，则错误可能在 Validate 时出现。
+	if err := tasks(options).ExecAll(false); err != nil {
+		if c.WantErr != "" && strings.Contains(err.Error(), c.WantErr) {
+			return c.checkAfter(fsys, root)
+		}
+		return err
+	}
+	if err := unchanged("validate"); err != nil {
+		return err
+	}
+
+	// Plan 不可修改文件，并且返回的操作应与预期相同。
+	if _, ok := recipe.(recipes.Planner); ok {
+		plan, err := tasks(options).Plan()
+		if err != nil {
+			return err
+		}
+		if err := unchanged("plan"); err != nil {
+			return err
+		}
+		var ops []recipes.Op
+		for _, tp := range plan.Tasks {
+			ops = append(ops, tp.Ops...)
+		}
+		if err := c.checkOps(root, ops); err != nil {
+			return err
+		}
+	} else if c.Ops != nil {
+		return fmt.Errorf("recipe %s does not support plan", recipe.Name())
+	}
+
+	// dry run 不可修改文件。
+	if _, ok := options["dry-run"]; ok {
+		dryRun := make(recipes.Options)
+		for k, v := range options {
+			dryRun[k] = v
+		}
+		dryRun["dry-run"] = "yes"
+		if err := tasks(dryRun).ExecAll(true); err != nil {
+			return fmt.Errorf("dry run: %w", err)
+		}
+		if err := unchanged("dry run"); err != nil {
+			return err
+		}
+	}
+
+	err = tasks(options).ExecAll(true)
+	switch {
+	case err != nil && c.WantErr == "":
+		return err
+	case err == nil && c.WantErr != "":
+		return fmt.Errorf("want error %q, got nil", c.WantErr)
+	case err != nil && !strings.Contains(err.Error(), c.WantErr):
+		return fmt.Errorf("want error %q, got %q", c.WantErr, err)
+	}
+	if err == nil {
+		if err := checkAffected(recipe); err != nil {
+			return err
+		}
+	}
+	return c.checkAfter(fsys, root)
+}
+
+// checkAffected 检查 recipe 报告的已处理的文件 (参见 recipes.Lister) 没有重复。
+func checkAffected(recipe recipes.Recipe) error {
+	lister, ok := recipe.(recipes.Lister)
+	if !ok {
+		return nil
+	}
+	seen := make(map[string]bool)
+	for _, name := range lister.Affected() {
+		if seen[name] {
+			return fmt.Errorf("affected files: %s is reported twice", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// checkOps 对比 recipe 报告的操作 ops (绝对路径) 与预期的操作 c.Ops.
+func (c Case) checkOps(root string, ops []recipes.Op) error {
+	if c.Ops == nil {
+		return nil
+	}
+	rel := func(name string) string {
+		if name == "" {
+			return ""
+		}
+		if r, err := filepath.Rel(root, name); err == nil {
+			return filepath.ToSlash(r)
+		}
+		return name
+	}
+	var got, want []string
+	for _, op := range ops {
+		got = append(got, recipes.Op{Kind: op.Kind, Src: rel(op.Src), Dest: rel(op.Dest)}.String())
+	}
+	for _, op := range c.Ops {
+		want = append(want, recipes.Op{Kind: op.Kind, Src: op.Src, Dest: op.Dest}.String())
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		return fmt.Errorf("unexpected ops:\n  got:\n    %s\n  want:\n    %s",
+			strings.Join(got, "\n    "), strings.Join(want, "\n    "))
+	}
+	return nil
+}
+
+// checkAfter 对比执行后的文件夹与预期的 c.After.
+func (c Case) checkAfter(fsys filesys.FS, root string) error {
+	if c.After == nil {
+		return nil
+	}
+	got, err := ReadTree(fsys, root)
+	if err != nil {
+		return err
+	}
+	if diff := diffTrees(got, c.After, false); len(diff) > 0 {
+		return fmt.Errorf("unexpected files:\n  %s", strings.Join(diff, "\n  "))
+	}
+	return nil
+}
+
+// WriteTree 在 fsys 的文件夹 root 里新建 tree 里的文件与文件夹。
+func WriteTree(fsys filesys.FS, root string, tree Tree) error {
+	for _, key := range sortedKeys(tree) {
+		file := tree[key]
+		name := filepath.Join(root, filepath.FromSlash(key))
+		if file.Dir {
+			if err := filesys.MkdirAll(fsys, name); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := filesys.MkdirAll(fsys, filepath.Dir(name)); err != nil {
+			return err
+		}
+		if err := writeFile(fsys, name, file.Content); err != nil {
+			return err
+		}
+		modTime := file.ModTime
+		if modTime.IsZero() {
+			modTime = recipes.ExampleModTime
+		}
+		if err := fsys.Chtimes(name, modTime, modTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeFile(fsys filesys.FS, name, content string) error {
+	f, err := fsys.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write([]byte(content)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadTree 读取 fsys 的文件夹 root 里的全部文件与文件夹（不包括 root 本身）。
+func ReadTree(fsys filesys.FS, root string) (Tree, error) {
+	tree := make(Tree)
+	err := fsys.WalkDir(root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, name)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if d.IsDir() {
+			tree[key] = File{Dir: true}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		data, err := filesys.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		tree[key] = File{Content: string(data), ModTime: info.ModTime()}
+		return nil
+	})
+	return tree, err
+}
+
+// sortedKeys 返回 tree 的全部 key (已排序)。
+func sortedKeys(tree Tree) (keys []string) {
+	for key := range tree {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return
+}
+
+// withParents 返回一个补全了上层文件夹的 Tree.
+func withParents(tree Tree) Tree {
+	full := make(Tree)
+	for key, file := range tree {
+		key = strings.TrimSuffix(key, "/")
+		full[key] = file
+		for dir := filepath.ToSlash(filepath.Dir(filepath.FromSlash(key))); dir != "."; {
+			full[dir] = File{Dir: true}
+			dir = filepath.ToSlash(filepath.Dir(filepath.FromSlash(dir)))
+		}
+	}
+	return full
+}
+
+// diffTrees 对比 got 与 want, 返回差异的描述。
+// strictTime 为 false 时，只对比 want 里指定了修改日期的文件的修改日期。
+func diffTrees(got, want Tree, strictTime bool) (diff []string) {
+	want = withParents(want)
+	for _, key := range sortedKeys(want) {
+		w := want[key]
+		g, ok := got[key]
+		switch {
+		case !ok:
+			diff = append(diff, "missing: "+key)
+		case g.Dir != w.Dir:
+			diff = append(diff, "file type differs: "+key)
+		case g.Dir:
+		case g.Content != w.Content:
+			diff = append(diff, fmt.Sprintf("content differs: %s: got %q, want %q", key, g.Content, w.Content))
+		case (strictTime || !w.ModTime.IsZero()) && !g.ModTime.Equal(w.ModTime):
+			diff = append(diff, fmt.Sprintf("mtime differs: %s: got %s, want %s",
+				key, g.ModTime.Format(time.RFC3339), w.ModTime.Format(time.RFC3339)))
+		}
+	}
+	for _, key := range sortedKeys(got) {
+		if _, ok := want[key]; !ok {
+			diff = append(diff, "unexpected: "+key)
+		}
+	}
+	return
+}
@@ -0,0 +1,85 @@
+package recipetest
+
+import (
+	"testing"
+
+	"github.com/ahui2016/gof/recipes"
+)
+
+func init() {
+	if err := recipes.Register(
+		new(recipes.Swap),
+		new(recipes.OneWaySync),
+		new(recipes.MoveNewFiles),
+	); err != nil {
+		panic(err)
+	}
+}
+
+func examples(t *testing.T, name string) []Case {
+	t.Helper()
+	cases, err := Examples(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cases) == 0 {
+		t.Fatal("no examples")
+	}
+	return cases
+}
+
+func TestRun(t *testing.T) {
+	for _, c := range examples(t, "") {
+		c := c
+		t.Run(c.Recipe+"/"+c.Name, func(t *testing.T) {
+			if err := Run(c); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestRunMem(t *testing.T) {
+	for _, c := range examples(t, "") {
+		c := c
+		t.Run(c.Recipe+"/"+c.Name, func(t *testing.T) {
+			if c.Workdir != "" {
+				t.Skip("RunMem does not support workdir")
+			}
+			if err := RunMem(c); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// acceptAll 确认全部操作，并记录被确认的操作。
+type acceptAll struct {
+	ops []recipes.Op
+}
+
+func (c *acceptAll) ConfirmOps(task string, ops []recipes.Op) ([]recipes.Op, error) {
+	c.ops = append(c.ops, ops...)
+	return ops, nil
+}
+
+func (c *acceptAll) ConfirmTask(task string) (bool, error) {
+	return true, nil
+}
+
+// TestConfirmed 在设定了 Confirmer 时执行 one-way-sync (先列出操作请使用者确认，然后才执行)。
+func TestConfirmed(t *testing.T) {
+	for _, c := range examples(t, "one-way-sync") {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			confirmer := new(acceptAll)
+			c.Confirmer = confirmer
+			if err := Run(c); err != nil {
+				t.Fatal(err)
+			}
+			if len(confirmer.ops) != len(c.Ops) {
+				t.Errorf("confirmed %d operations, want %d", len(confirmer.ops), len(c.Ops))
+			}
+		})
+	}
+}
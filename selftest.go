@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/ahui2016/gof/recipes"
+	"github.com/ahui2016/gof/recipetest"
+)
+
+// runSelftest 在临时文件夹里运行已注册的 recipe 的例子 (参见 recipes.Exampler)，报告每个例子是否通过。
+// 用法: gof selftest [recipe], 指定 recipe 时只运行该 recipe 的例子。
+func runSelftest() error {
+	if len(names) > 1 {
+		return fmt.Errorf("usage: gof selftest [recipe]")
+	}
+	all := recipes.Names()
+	if len(names) == 1 {
+		recipe, ok := recipes.Lookup(names[0])
+		if !ok {
+			return fmt.Errorf("not found recipe: %s", names[0])
+		}
+		all = []string{recipe.Name()}
+	}
+
+	failed, total := 0, 0
+	for _, name := range all {
+		cases, err := recipetest.Examples(name)
+		if err != nil {
+			return err
+		}
+		if len(cases) == 0 {
+			fmt.Printf("SKIP  %s (no examples)\n", name)
+			continue
+		}
+		for _, c := range cases {
+			total++
+			if err := quietly(func() error { return recipetest.Run(c) }); err != nil {
+				failed++
+				fmt.Printf("FAIL  %s: %s\n", name, c.Name)
+				fmt.Printf("      %s\n", strings.ReplaceAll(err.Error(), "\n", "\n      "))
+				continue
+			}
+			fmt.Printf("PASS  %s: %s\n", name, c.Name)
+		}
+	}
+	fmt.Println()
+	fmt.Printf("%d passed, %d failed\n", total-failed, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d examples failed", failed, total)
+	}
+	return nil
+}
+
+// quietly 执行 fn, 并丢弃执行期间 recipe 输出到 stdout 与 log 的信息，以免打乱测试报告。
+func quietly(fn func() error) error {
+	restore, err := discardStdout()
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	logOut := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(logOut)
+	return fn()
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !freebsd && !windows
+// +build !linux,!darwin,!freebsd,!windows
+
+package util
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// FreeSpace 在不支持的系统上总是返回错误。
+func FreeSpace(dir string) (uint64, error) {
+	return 0, fmt.Errorf("free space is unsupported on this platform (%s)", runtime.GOOS)
+}
@@ -0,0 +1,19 @@
+//go:build windows
+// +build windows
+
+package util
+
+import "golang.org/x/sys/windows"
+
+// FreeSpace 返回文件夹 dir 所在的硬盘分区的可用空间（字节数）。
+func FreeSpace(dir string) (uint64, error) {
+	path, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, err
+	}
+	var free uint64
+	if err := windows.GetDiskFreeSpaceEx(path, &free, nil, nil); err != nil {
+		return 0, err
+	}
+	return free, nil
+}
@@ -1,13 +1,16 @@
 package util
 
 import (
+	"bytes"
 	"encoding/hex"
-	"errors"
 	"fmt"
 	"io"
-	"io/fs"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
+	"github.com/ahui2016/gof/filesys"
 	"golang.org/x/crypto/blake2b"
 )
 
@@ -34,27 +37,17 @@ func Panic(err error) {
 
 // PathIsNotExist 找不到名为 name 的文件时返回 true, 否则返回 false
 func PathIsNotExist(name string) (ok bool, err error) {
-	_, err = os.Lstat(name)
-	if errors.Is(err, fs.ErrNotExist) {
-		ok = true
-		err = nil
-	}
-	return
+	return filesys.PathIsNotExist(filesys.OS{}, name)
 }
 
 // PathIsExist 找到名为 name 的文件时返回 true, 否则返回 false
 func PathIsExist(name string) (bool, error) {
-	ok, err := PathIsNotExist(name)
-	return !ok, err
+	return filesys.PathIsExist(filesys.OS{}, name)
 }
 
 // FindFile returns a better error massage if cannot find the file.
 func FindFile(name string) error {
-	_, err := os.Lstat(name)
-	if errors.Is(err, fs.ErrNotExist) {
-		return fmt.Errorf("the system cannot find: %s", name)
-	}
-	return err
+	return filesys.FindFile(filesys.OS{}, name)
 }
 
 func StrSliceFilter(arr []string, test func(string) bool) (result []string) {
@@ -87,30 +80,21 @@ func Sha256Hex(data []byte) string {
 // FileSha256Hex 返回文件 name 的 hex 字符串。
 // 虽然函数名是 Sha256, 但实际上采用 BLAKE2b 算法。
 func FileSha256Hex(name string) (string, error) {
-	fileBytes, err := os.ReadFile(name)
+	return FSFileSha256Hex(filesys.OS{}, name)
+}
+
+// FSFileSha256Hex 与 FileSha256Hex 相同，但通过 fsys 读取文件。
+func FSFileSha256Hex(fsys filesys.FS, name string) (string, error) {
+	fileBytes, err := filesys.ReadFile(fsys, name)
 	if err != nil {
 		return "", err
 	}
 	return Sha256Hex(fileBytes), nil
 }
 
-// https://stackoverflow.com/questions/30376921/how-do-you-copy-a-file-in-go
+// CopyFile 把文件 sourcePath 复制为 destPath (参见 filesys.CopyFile)。
 func CopyFile(destPath, sourcePath string) error {
-	inputFile, err := os.Open(sourcePath)
-	if err != nil {
-		return err
-	}
-	defer inputFile.Close()
-
-	outputFile, err := os.Create(destPath)
-	if err != nil {
-		return err
-	}
-	defer outputFile.Close()
-
-	_, err1 := io.Copy(outputFile, inputFile)
-	err2 := outputFile.Sync()
-	return WrapErrors(err1, err2)
+	return filesys.CopyFile(filesys.OS{}, destPath, sourcePath)
 }
 
 // IntMin computes the minimum of the two int args
@@ -120,3 +104,114 @@ func IntMin(a, b int) int {
 	}
 	return b
 }
+
+// ReadNames 从文件 name 中读取文件名列表，name 为 "-" 时从 stdin 读取。
+// nul 为 true 时以 NUL 字符分隔（与 find -print0 配合使用）；
+// nul 为 false 时，如果内容中含有 NUL 字符也按 NUL 分隔，否则按换行符分隔。
+// 空行会被忽略。
+func ReadNames(name string, nul bool) ([]string, error) {
+	var data []byte
+	var err error
+	if name == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return SplitNames(data, nul || bytes.IndexByte(data, 0) >= 0), nil
+}
+
+// SplitNames 把 data 分割为文件名列表，nul 为 true 时以 NUL 字符分隔，否则按换行符分隔。
+func SplitNames(data []byte, nul bool) (names []string) {
+	sep := "\n"
+	if nul {
+		sep = "\x00"
+	}
+	for _, name := range strings.Split(string(data), sep) {
+		if !nul {
+			name = strings.TrimSuffix(name, "\r")
+		}
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return
+}
+
+// PortablePath 把 name 里的反斜杠转换为当前系统的路径分隔符。
+// 在 Windows 里不需要转换（正斜杠与反斜杠都可以作为分隔符）。
+func PortablePath(name string) string {
+	if filepath.Separator == '\\' {
+		return name
+	}
+	return strings.ReplaceAll(name, `\`, string(filepath.Separator))
+}
+
+// HasLiteralSeparator 检查 name 里是否含有在当前系统中不被当作分隔符的路径分隔符
+// (比如在 Linux 里，反斜杠只是普通字符)。
+func HasLiteralSeparator(name string) bool {
+	return filepath.Separator != '\\' && strings.Contains(name, `\`)
+}
+
+// ExpandVars 把 s 里的 ${name} 替换为 vars[name].
+// 在 vars 里找不到的变量会保持原样，以便之后再进行替换。
+func ExpandVars(s string, vars map[string]string) string {
+	var b strings.Builder
+	for {
+		start := strings.Index(s, "${")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(s[start:], "}")
+		if end < 0 {
+			break
+		}
+		end += start
+		b.WriteString(s[:start])
+		if value, ok := vars[s[start+2:end]]; ok {
+			b.WriteString(value)
+		} else {
+			b.WriteString(s[start : end+1])
+		}
+		s = s[end+1:]
+	}
+	b.WriteString(s)
+	return b.String()
+}
+
+// ParseSize 把 "500MB", "10G", "1024" 等表示文件大小的字符串转换为字节数。
+// 单位不区分大小写，1K = 1024 bytes.
+func ParseSize(size string) (uint64, error) {
+	s := strings.ToUpper(strings.TrimSpace(size))
+	s = strings.TrimSuffix(s, "B")
+	units := map[string]uint64{"K": 1 << 10, "M": 1 << 20, "G": 1 << 30, "T": 1 << 40}
+	var unit uint64 = 1
+	if len(s) > 0 {
+		if u, ok := units[s[len(s)-1:]]; ok {
+			unit = u
+			s = s[:len(s)-1]
+		}
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size: %s", size)
+	}
+	return uint64(n * float64(unit)), nil
+}
+
+// FormatSize 把字节数转换为便于阅读的字符串，比如 1536 -> "1.5 KB" (与 ParseSize 相反)。
+func FormatSize(size int64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	n := float64(size)
+	i := 0
+	for n >= 1024 && i < len(units)-1 {
+		n /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d B", size)
+	}
+	return fmt.Sprintf("%.1f %s", n, units[i])
+}